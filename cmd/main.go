@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 var config *configs.Config
@@ -41,7 +42,11 @@ func main() {
 		log.Fatalf("Application encountered an error")
 	}
 
-	if err := app.Shutdown(ctx); err != nil {
+	// ctx is cancelled by now, so shutdown gets a deadline of its own
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+
+	if err := app.Shutdown(shutdownCtx); err != nil {
 		log.Errorf("Error during application shutdown")
 	}
 	log.Infof("Application terminated gracefully")
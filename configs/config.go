@@ -2,16 +2,21 @@ package configs
 
 import (
 	"fmt"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
 	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/utils"
 	"gopkg.in/yaml.v3"
+	"net/url"
 	"os"
 	"path/filepath"
 	"time"
 )
 
+// RetryConfig is a retry policy. DelayType is fixed or backoff; backoff doubles the delay after
+// every failed attempt, up to MaxDelay when it is set.
 type RetryConfig struct {
 	Attempts  uint          `yaml:"attempts"`
 	Delay     time.Duration `yaml:"delay"`
+	MaxDelay  time.Duration `yaml:"max_delay"`
 	DelayType string        `yaml:"delay_type"`
 }
 
@@ -20,22 +25,91 @@ type DatabaseConfig struct {
 	Retry RetryConfig `yaml:"retry"`
 }
 
+// RPCConfig selects the JSON-RPC endpoints. URL, when set, is used instead of the cluster's
+// public endpoint, e.g. for a provider that takes its API key as a header. Endpoints, when set,
+// replaces both with a pool of providers calls are routed across by health. Keep them on the same
+// cluster as the WebSocket endpoint.
+type RPCConfig struct {
+	Cluster             enums.Cluster       `yaml:"cluster"`
+	URL                 string              `yaml:"url"`
+	Headers             map[string]string   `yaml:"headers"`
+	Timeout             time.Duration       `yaml:"timeout"`
+	Endpoints           []RPCEndpointConfig `yaml:"endpoints"`
+	HealthCheckInterval time.Duration       `yaml:"health_check_interval"`
+	RateLimit           RPCRateLimitConfig  `yaml:"rate_limit"`
+	Batch               RPCBatchConfig      `yaml:"batch"`
+}
+
+// RPCBatchConfig coalesces concurrent transaction fetches into JSON-RPC batch requests of up to
+// MaxSize calls, waiting at most Window for a batch to fill. A MaxSize below two disables it.
+type RPCBatchConfig struct {
+	MaxSize int           `yaml:"max_size"`
+	Window  time.Duration `yaml:"window"`
+}
+
+// RPCRateLimitConfig is the request budget of each endpoint; zero rates are unlimited.
+// BackfillShare is the part of every budget backfill may use, the rest is reserved for live
+// processing.
+type RPCRateLimitConfig struct {
+	RequestsPerSecond float64            `yaml:"requests_per_second"`
+	Methods           map[string]float64 `yaml:"methods"`
+	BackfillShare     float64            `yaml:"backfill_share"`
+}
+
+// RPCEndpointConfig is one provider of the pool. Name labels its metrics and defaults to the
+// URL's host.
+type RPCEndpointConfig struct {
+	Name    string            `yaml:"name"`
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	// RequestsPerSecond overrides rate_limit.requests_per_second for this provider's plan.
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+}
+
+// WebSocketConfig locates the streaming endpoint. Retry paces reconnects after the connection is
+// lost; zero attempts keeps reconnecting until it succeeds. The connection is pinged every
+// PingInterval and counts as lost when nothing was read for PongTimeout, or when no notification
+// arrived for NotificationTimeout.
 type WebSocketConfig struct {
-	Scheme string      `yaml:"scheme"`
-	Host   string      `yaml:"host"`
-	Path   string      `yaml:"path"`
-	Retry  RetryConfig `yaml:"retry"`
+	Scheme              string        `yaml:"scheme"`
+	Host                string        `yaml:"host"`
+	Path                string        `yaml:"path"`
+	Retry               RetryConfig   `yaml:"retry"`
+	PingInterval        time.Duration `yaml:"ping_interval"`
+	PongTimeout         time.Duration `yaml:"pong_timeout"`
+	NotificationTimeout time.Duration `yaml:"notification_timeout"`
 }
 
 type ServicesConfig struct {
-	Wallets []string `yaml:"wallets"`
-	Tokens  []string `yaml:"tokens"`
+	Wallets []WalletConfig `yaml:"wallets"`
+	Tokens  []string       `yaml:"tokens"`
+}
+
+// WalletConfig is a monitored wallet. Direction defaults to both when omitted.
+type WalletConfig struct {
+	Address   string          `yaml:"address"`
+	Direction enums.Direction `yaml:"direction"`
 }
 
+// UnmarshalYAML accepts either a bare address or an address with a direction.
+func (w *WalletConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		w.Address = value.Value
+		return nil
+	}
+	type plain WalletConfig
+	return value.Decode((*plain)(w))
+}
+
+// CoordinatorConfig configures the live transaction monitor. MaxConcurrency bounds how many
+// notifications are processed at once and defaults to 20.
 type CoordinatorConfig struct {
-	Retry RetryConfig `yaml:"retry"`
+	Retry          RetryConfig `yaml:"retry"`
+	MaxConcurrency int64       `yaml:"max_concurrency"`
 }
 
+// AppConfig configures the application. Addr is where the operator HTTP endpoint, serving
+// /metrics and the backfill job API, listens.
 type AppConfig struct {
 	Env             utils.Environment `yaml:"env"`
 	Addr            string            `yaml:"addr"`
@@ -51,15 +125,28 @@ type LogConfig struct {
 type Config struct {
 	App         AppConfig         `yaml:"app"`
 	Database    DatabaseConfig    `yaml:"database"`
+	RPC         RPCConfig         `yaml:"rpc"`
 	WebSocket   WebSocketConfig   `yaml:"websocket"`
 	Services    ServicesConfig    `yaml:"services"`
 	Coordinator CoordinatorConfig `yaml:"coordinator"`
 	Backfill    BackfillConfig    `yaml:"backfill"`
+	Decoders    DecodersConfig    `yaml:"decoders"`
+}
+
+// DecodersConfig configures the optional instruction decoders. Anchor IDLs are only loaded when
+// AnchorIDLDirectory is set.
+type DecodersConfig struct {
+	AnchorIDLDirectory string `yaml:"anchor_idl_directory"`
 }
 
+// BackfillConfig configures backfill jobs. Slot jobs scan their blocks ChunkSize slots at a time;
+// wallet jobs page the signature history of the monitored wallets and their token accounts.
+// JobPollInterval is how often the job runner looks for queued jobs and checks the running job's
+// status.
 type BackfillConfig struct {
-	MaxConcurrency int64 `yaml:"max_concurrency"`
-	ChunkSize      int64 `yaml:"chunk_size"`
+	MaxConcurrency  int64         `yaml:"max_concurrency"`
+	ChunkSize       int64         `yaml:"chunk_size"`
+	JobPollInterval time.Duration `yaml:"job_poll_interval"`
 }
 
 // Load reads and parses the YAML configuration file.
@@ -92,9 +179,63 @@ func Load(configPath string) (*Config, error) {
 }
 
 func validateConfig(cfg *Config) error {
+	if cfg.App.Addr == "" {
+		return fmt.Errorf("app.addr is required")
+	}
 	if cfg.Database.URI == "" {
 		return fmt.Errorf("database.uri is required")
 	}
+	if cfg.RPC.Cluster == "" {
+		cfg.RPC.Cluster = enums.ClusterMainnet
+	}
+	if !cfg.RPC.Cluster.IsValid() {
+		return fmt.Errorf("rpc.cluster must be one of mainnet-beta, devnet, testnet or localnet")
+	}
+	if cfg.RPC.Timeout < 0 {
+		return fmt.Errorf("rpc.timeout must not be negative")
+	}
+	for i := range cfg.RPC.Endpoints {
+		endpoint := &cfg.RPC.Endpoints[i]
+		if endpoint.URL == "" {
+			return fmt.Errorf("rpc.endpoints[%d].url is required", i)
+		}
+		if endpoint.RequestsPerSecond < 0 {
+			return fmt.Errorf("rpc.endpoints[%d].requests_per_second must not be negative", i)
+		}
+		if endpoint.Name == "" {
+			parsed, err := url.Parse(endpoint.URL)
+			if err != nil || parsed.Host == "" {
+				return fmt.Errorf("rpc.endpoints[%d].url is invalid", i)
+			}
+			endpoint.Name = parsed.Host
+		}
+	}
+	if cfg.RPC.RateLimit.RequestsPerSecond < 0 {
+		return fmt.Errorf("rpc.rate_limit.requests_per_second must not be negative")
+	}
+	for method, requestsPerSecond := range cfg.RPC.RateLimit.Methods {
+		if requestsPerSecond < 0 {
+			return fmt.Errorf("rpc.rate_limit.methods.%s must not be negative", method)
+		}
+	}
+	if cfg.RPC.RateLimit.BackfillShare == 0 {
+		cfg.RPC.RateLimit.BackfillShare = 0.5
+	}
+	if cfg.RPC.RateLimit.BackfillShare <= 0 || cfg.RPC.RateLimit.BackfillShare >= 1 {
+		return fmt.Errorf("rpc.rate_limit.backfill_share must be between 0 and 1")
+	}
+	if cfg.RPC.Batch.MaxSize < 0 {
+		return fmt.Errorf("rpc.batch.max_size must not be negative")
+	}
+	if cfg.RPC.Batch.Window < 0 {
+		return fmt.Errorf("rpc.batch.window must not be negative")
+	}
+	if cfg.RPC.HealthCheckInterval == 0 {
+		cfg.RPC.HealthCheckInterval = 10 * time.Second
+	}
+	if cfg.RPC.HealthCheckInterval < 0 {
+		return fmt.Errorf("rpc.health_check_interval must not be negative")
+	}
 	if cfg.WebSocket.Scheme == "" {
 		return fmt.Errorf("websocket.scheme is required")
 	}
@@ -104,11 +245,69 @@ func validateConfig(cfg *Config) error {
 	if cfg.WebSocket.Path == "" {
 		return fmt.Errorf("websocket.path is required")
 	}
+	if cfg.WebSocket.Retry.Delay == 0 {
+		cfg.WebSocket.Retry.Delay = time.Second
+	}
+	if cfg.WebSocket.Retry.Delay < 0 || cfg.WebSocket.Retry.MaxDelay < 0 {
+		return fmt.Errorf("websocket.retry delays must not be negative")
+	}
+	if cfg.WebSocket.Retry.DelayType == "" {
+		cfg.WebSocket.Retry.DelayType = "fixed"
+	}
+	if cfg.WebSocket.Retry.DelayType != "fixed" && cfg.WebSocket.Retry.DelayType != "backoff" {
+		return fmt.Errorf("websocket.retry.delay_type must be fixed or backoff")
+	}
+	if cfg.WebSocket.PingInterval == 0 {
+		cfg.WebSocket.PingInterval = 10 * time.Second
+	}
+	if cfg.WebSocket.PongTimeout == 0 {
+		cfg.WebSocket.PongTimeout = 30 * time.Second
+	}
+	if cfg.WebSocket.NotificationTimeout == 0 {
+		cfg.WebSocket.NotificationTimeout = 30 * time.Second
+	}
+	if cfg.WebSocket.PingInterval < 0 || cfg.WebSocket.NotificationTimeout < 0 {
+		return fmt.Errorf("websocket.ping_interval and websocket.notification_timeout must not be negative")
+	}
+	if cfg.WebSocket.PongTimeout <= cfg.WebSocket.PingInterval {
+		return fmt.Errorf("websocket.pong_timeout must be longer than websocket.ping_interval")
+	}
 	if len(cfg.Services.Wallets) == 0 {
 		return fmt.Errorf("services.wallets must have at least one entry")
 	}
+	for i := range cfg.Services.Wallets {
+		wallet := &cfg.Services.Wallets[i]
+		if wallet.Address == "" {
+			return fmt.Errorf("services.wallets[%d].address is required", i)
+		}
+		if wallet.Direction == "" {
+			wallet.Direction = enums.DirectionBoth
+		}
+		if !wallet.Direction.IsValid() {
+			return fmt.Errorf("services.wallets[%d].direction must be one of inbound, outbound or both", i)
+		}
+	}
 	if len(cfg.Services.Tokens) == 0 {
 		return fmt.Errorf("services.tokens must have at least one entry")
 	}
+	if cfg.Backfill.MaxConcurrency < 1 {
+		return fmt.Errorf("backfill.max_concurrency must be at least 1")
+	}
+	// getBlocks lists at most 500,000 slots per call
+	if cfg.Backfill.ChunkSize < 1 || cfg.Backfill.ChunkSize > 500000 {
+		return fmt.Errorf("backfill.chunk_size must be between 1 and 500000 slots")
+	}
+	if cfg.Backfill.JobPollInterval == 0 {
+		cfg.Backfill.JobPollInterval = 5 * time.Second
+	}
+	if cfg.Backfill.JobPollInterval < 0 {
+		return fmt.Errorf("backfill.job_poll_interval must not be negative")
+	}
+	if cfg.Coordinator.MaxConcurrency == 0 {
+		cfg.Coordinator.MaxConcurrency = 20
+	}
+	if cfg.Coordinator.MaxConcurrency < 0 {
+		return fmt.Errorf("coordinator.max_concurrency must not be negative")
+	}
 	return nil
 }
@@ -0,0 +1,11 @@
+package repositories
+
+import (
+	"context"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+)
+
+type AddressCursor interface {
+	Get(ctx context.Context, address string) (*entity.AddressCursor, error)
+	Save(ctx context.Context, cursor *entity.AddressCursor) error
+}
@@ -0,0 +1,22 @@
+package repositories
+
+import (
+	"context"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BackfillJob persists backfill jobs. Status changes are conditional on the current status, so
+// the runner and operators cannot overwrite each other's transitions; the bool results report
+// whether the job was in an expected status.
+type BackfillJob interface {
+	Create(ctx context.Context, job *entity.BackfillJob) error
+	Get(ctx context.Context, id primitive.ObjectID) (*entity.BackfillJob, error)
+	List(ctx context.Context) ([]entity.BackfillJob, error)
+	Transition(ctx context.Context, id primitive.ObjectID, from []enums.BackfillJobStatus, to enums.BackfillJobStatus) (bool, error)
+	ClaimPending(ctx context.Context) (*entity.BackfillJob, error)
+	RequeueRunning(ctx context.Context) (int64, error)
+	SaveProgress(ctx context.Context, job *entity.BackfillJob) error
+	Finish(ctx context.Context, job *entity.BackfillJob) (bool, error)
+}
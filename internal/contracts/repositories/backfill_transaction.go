@@ -1,8 +1,15 @@
 package repositories
 
-import "context"
+import (
+	"context"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+)
 
 type BackfillTransactionRepository interface {
-	GetLastProcessedBlock(ctx context.Context) (int64, error)
-	UpdateLastProcessedBlock(ctx context.Context, blockHeight int64) error
+	GetLastProcessedSlot(ctx context.Context) (uint64, error)
+	UpdateLastProcessedSlot(ctx context.Context, slot uint64) error
+	GetCompletedSlotRanges(ctx context.Context) ([]entity.SlotRange, error)
+	CompleteSlotRange(ctx context.Context, slotRange entity.SlotRange) (uint64, error)
+	GetLastStreamSlot(ctx context.Context) (uint64, error)
+	UpdateLastStreamSlot(ctx context.Context, slot uint64) error
 }
@@ -0,0 +1,11 @@
+package repositories
+
+import (
+	"context"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+)
+
+type Balance interface {
+	Get(ctx context.Context, account string) (*entity.Balance, error)
+	Save(ctx context.Context, balance *entity.Balance, change *entity.BalanceChange) error
+}
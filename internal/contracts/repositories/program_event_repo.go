@@ -0,0 +1,10 @@
+package repositories
+
+import (
+	"context"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+)
+
+type ProgramEvent interface {
+	Save(ctx context.Context, event *entity.ProgramEvent) error
+}
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrInvalidBackfillJob is returned for jobs that cannot be created as requested.
+	ErrInvalidBackfillJob = errors.New("invalid backfill job")
+	// ErrBackfillJobNotFound is returned for jobs that do not exist.
+	ErrBackfillJobNotFound = errors.New("backfill job not found")
+	// ErrBackfillJobStatus is returned when a job cannot be moved from its current status.
+	ErrBackfillJobStatus = errors.New("backfill job status does not allow it")
+)
+
+// BackfillJobs is what operators use to manage backfill jobs.
+type BackfillJobs interface {
+	Create(ctx context.Context, job *entity.BackfillJob) error
+	Get(ctx context.Context, id primitive.ObjectID) (*entity.BackfillJob, error)
+	List(ctx context.Context) ([]entity.BackfillJob, error)
+	Pause(ctx context.Context, id primitive.ObjectID) error
+	Resume(ctx context.Context, id primitive.ObjectID) error
+	Cancel(ctx context.Context, id primitive.ObjectID) error
+}
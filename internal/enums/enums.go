@@ -8,12 +8,158 @@ const (
 
 	ProgramSubscribe   SubscriptionAction = "programSubscribe"
 	ProgramUnsubscribe SubscriptionAction = "programUnsubscribe"
+
+	SlotSubscribe   SubscriptionAction = "slotSubscribe"
+	SlotUnsubscribe SubscriptionAction = "slotUnsubscribe"
+
+	AccountSubscribe   SubscriptionAction = "accountSubscribe"
+	AccountUnsubscribe SubscriptionAction = "accountUnsubscribe"
 )
 
 func IsSubscribe(action SubscriptionAction) bool {
-	return action == LogsSubscribe || action == ProgramSubscribe
+	return action == LogsSubscribe || action == ProgramSubscribe || action == SlotSubscribe || action == AccountSubscribe
 }
 
 func IsUnsubscribe(action SubscriptionAction) bool {
-	return action == LogsUnsubscribe || action == ProgramUnsubscribe
+	return action == LogsUnsubscribe || action == ProgramUnsubscribe || action == SlotUnsubscribe || action == AccountUnsubscribe
+}
+
+// Cluster names a public Solana cluster. A custom RPC URL overrides it.
+type Cluster string
+
+const (
+	ClusterMainnet  Cluster = "mainnet-beta"
+	ClusterDevnet   Cluster = "devnet"
+	ClusterTestnet  Cluster = "testnet"
+	ClusterLocalnet Cluster = "localnet"
+)
+
+func (c Cluster) IsValid() bool {
+	return c == ClusterMainnet || c == ClusterDevnet || c == ClusterTestnet || c == ClusterLocalnet
+}
+
+// RPCOutcome classifies how an RPC endpoint answered a call.
+type RPCOutcome string
+
+const (
+	RPCSuccess RPCOutcome = "success"
+	// RPCError is an error returned by the node itself, e.g. for an unknown slot. The endpoint is
+	// healthy and another one would answer the same.
+	RPCError       RPCOutcome = "rpc_error"
+	RPCRateLimited RPCOutcome = "rate_limited"
+	// RPCFailed covers transport errors and 5xx responses.
+	RPCFailed RPCOutcome = "failed"
+)
+
+// StreamDisconnectReason classifies why the WebSocket connection was dropped.
+type StreamDisconnectReason string
+
+const (
+	StreamError StreamDisconnectReason = "error"
+	// StreamTimeout is a connection that stopped answering pings.
+	StreamTimeout StreamDisconnectReason = "timeout"
+	// StreamStale is a connection dropped by the watchdog because no notification arrived in time.
+	StreamStale StreamDisconnectReason = "stale"
+)
+
+// RPCTraffic is the class an RPC call is budgeted under.
+type RPCTraffic string
+
+const (
+	RPCTrafficLive     RPCTraffic = "live"
+	RPCTrafficBackfill RPCTraffic = "backfill"
+	// RPCTrafficHealthCheck is the endpoint pool's own slot polling, which draws from no budget.
+	RPCTrafficHealthCheck RPCTraffic = "health_check"
+)
+
+type TokenInstruction string
+
+const (
+	TokenTransfer        TokenInstruction = "transfer"
+	TokenTransferChecked TokenInstruction = "transferChecked"
+	TokenMintTo          TokenInstruction = "mintTo"
+	TokenMintToChecked   TokenInstruction = "mintToChecked"
+	TokenBurn            TokenInstruction = "burn"
+	TokenBurnChecked     TokenInstruction = "burnChecked"
+
+	SystemTransfer         TokenInstruction = "systemTransfer"
+	SystemTransferWithSeed TokenInstruction = "systemTransferWithSeed"
+	SystemCreateAccount    TokenInstruction = "systemCreateAccount"
+
+	// TokenBalanceChange marks movements derived from pre/post token balances rather than a decoded instruction.
+	TokenBalanceChange TokenInstruction = "balanceChange"
+)
+
+type Direction string
+
+const (
+	DirectionInbound  Direction = "inbound"
+	DirectionOutbound Direction = "outbound"
+	DirectionBoth     Direction = "both"
+)
+
+func (d Direction) IsValid() bool {
+	return d == DirectionInbound || d == DirectionOutbound || d == DirectionBoth
+}
+
+// Allows reports whether a wallet configured with d should record movements in the given direction.
+func (d Direction) Allows(direction Direction) bool {
+	return d == DirectionBoth || d == direction
 }
+
+type EventKind string
+
+const (
+	EventTransfer EventKind = "transfer"
+	EventMint     EventKind = "mint"
+	EventBurn     EventKind = "burn"
+	EventMemo     EventKind = "memo"
+	EventStake    EventKind = "stake"
+	EventSwap     EventKind = "swap"
+	EventProgram  EventKind = "program"
+)
+
+type ProgramEventSource string
+
+const (
+	ProgramEventInstruction ProgramEventSource = "instruction"
+	ProgramEventLog         ProgramEventSource = "log"
+)
+
+// BackfillMode selects how a backfill job finds missed transactions: by scanning every block of a
+// slot range, or by paging the signature history of the monitored addresses.
+type BackfillMode string
+
+const (
+	BackfillSlots   BackfillMode = "slots"
+	BackfillWallets BackfillMode = "wallets"
+)
+
+func (m BackfillMode) IsValid() bool {
+	return m == BackfillSlots || m == BackfillWallets
+}
+
+// BackfillJobStatus is the lifecycle state of a backfill job. Pending jobs wait for the runner;
+// completed, failed and cancelled jobs are final.
+type BackfillJobStatus string
+
+const (
+	BackfillJobPending   BackfillJobStatus = "pending"
+	BackfillJobRunning   BackfillJobStatus = "running"
+	BackfillJobPaused    BackfillJobStatus = "paused"
+	BackfillJobCompleted BackfillJobStatus = "completed"
+	BackfillJobFailed    BackfillJobStatus = "failed"
+	BackfillJobCancelled BackfillJobStatus = "cancelled"
+)
+
+func (s BackfillJobStatus) IsFinal() bool {
+	return s == BackfillJobCompleted || s == BackfillJobFailed || s == BackfillJobCancelled
+}
+
+type StakeAction string
+
+const (
+	StakeDelegate   StakeAction = "delegate"
+	StakeDeactivate StakeAction = "deactivate"
+	StakeWithdraw   StakeAction = "withdraw"
+)
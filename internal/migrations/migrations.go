@@ -1 +1,258 @@
 package migrations
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/amount"
+	"github.com/mr-tron/base58"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration upgrades stored documents to a newer schema. Every migration must be safe to run
+// again on documents it already upgraded.
+type Migration struct {
+	Name string
+	Up   func(ctx context.Context, db *mongo.Database) error
+}
+
+var migrations = []Migration{
+	{Name: "transactions_v2_base58_signatures", Up: migrateTransactionsToV2},
+	{Name: "transactions_v3_exact_amounts", Up: migrateTransactionsToV3},
+	{Name: "metadata_drop_last_processed_block", Up: dropLastProcessedBlock},
+	{Name: "transactions_unique_movements", Up: uniqueTransactionMovements},
+	{Name: "program_events_unique_events", Up: uniqueProgramEvents},
+	{Name: "balance_changes_unique_changes", Up: uniqueBalanceChanges},
+	{Name: "transactions_unique_movements_by_account", Up: uniqueTransactionMovementsByAccount},
+}
+
+// Run applies every migration in order.
+func Run(ctx context.Context, client *mongo.Client) error {
+	db := client.Database("solsniffer")
+	for _, migration := range migrations {
+		if err := migration.Up(ctx, db); err != nil {
+			return fmt.Errorf("migration %s failed: %w", migration.Name, err)
+		}
+	}
+	return nil
+}
+
+// migrateTransactionsToV2 re-encodes the hex signatures of version 1 transaction documents as
+// base58. The on-chain context added in version 2 cannot be recovered and is left unset.
+func migrateTransactionsToV2(ctx context.Context, db *mongo.Database) error {
+	collection := db.Collection("transactions")
+
+	cursor, err := collection.Find(ctx, bson.M{"schema_version": bson.M{"$exists": false}})
+	if err != nil {
+		return fmt.Errorf("failed to find version 1 transactions: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var document struct {
+			ID   primitive.ObjectID `bson:"_id"`
+			Hash string             `bson:"hash"`
+		}
+		if err := cursor.Decode(&document); err != nil {
+			return fmt.Errorf("failed to decode transaction: %v", err)
+		}
+
+		update := bson.M{"schema_version": 2}
+		if signature, err := hex.DecodeString(document.Hash); err == nil {
+			update["hash"] = base58.Encode(signature)
+		}
+
+		if _, err := collection.UpdateByID(ctx, document.ID, bson.M{"$set": update}); err != nil {
+			return fmt.Errorf("failed to migrate transaction %s: %v", document.ID.Hex(), err)
+		}
+	}
+	return cursor.Err()
+}
+
+// migrateTransactionsToV3 replaces float64 amounts with exact Decimal128 amounts. Version 2
+// documents carry the raw integer amount and decimals, which are used when present; version 1
+// documents only have the float, which is kept at the precision it was stored with. The digits
+// the float printed say nothing about the mint's decimals, so raw_amount and decimals stay unset
+// for them.
+func migrateTransactionsToV3(ctx context.Context, db *mongo.Database) error {
+	collection := db.Collection("transactions")
+
+	cursor, err := collection.Find(ctx, bson.M{"schema_version": bson.M{"$lt": 3}})
+	if err != nil {
+		return fmt.Errorf("failed to find version 2 transactions: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var document struct {
+			ID        primitive.ObjectID `bson:"_id"`
+			Amount    amount.Amount      `bson:"amount"`
+			RawAmount string             `bson:"raw_amount"`
+			Decimals  uint8              `bson:"decimals"`
+		}
+		if err := cursor.Decode(&document); err != nil {
+			return fmt.Errorf("failed to decode transaction: %v", err)
+		}
+
+		update := bson.M{
+			"schema_version": 3,
+			"amount":         document.Amount,
+		}
+		if document.RawAmount != "" {
+			if parsed, err := amount.Parse(document.RawAmount, document.Decimals); err == nil {
+				update["amount"] = parsed
+				update["raw_amount"] = parsed.RawString()
+				update["decimals"] = parsed.Decimals()
+			}
+		}
+		if _, err := collection.UpdateByID(ctx, document.ID, bson.M{"$set": update}); err != nil {
+			return fmt.Errorf("failed to migrate transaction %s: %v", document.ID.Hex(), err)
+		}
+	}
+	return cursor.Err()
+}
+
+// dropLastProcessedBlock removes the backfill watermark that was kept as a block height. Backfill
+// now tracks slots, which a height cannot be converted to.
+func dropLastProcessedBlock(ctx context.Context, db *mongo.Database) error {
+	if _, err := db.Collection("metadata").DeleteOne(ctx, bson.M{"_id": "last_processed_block"}); err != nil {
+		return fmt.Errorf("failed to drop last processed block: %v", err)
+	}
+	return nil
+}
+
+// movementKey identifies a movement within its transaction: the instruction that made it, if any,
+// and the accounts it moved between. Movements derived from balance deltas have no instruction and
+// carry the wallet as their source or destination.
+var movementKey = bson.D{
+	{Key: "hash", Value: 1},
+	{Key: "instruction_index", Value: 1},
+	{Key: "inner_instruction_index", Value: 1},
+	{Key: "direction", Value: 1},
+	{Key: "token_mint", Value: 1},
+	{Key: "source", Value: 1},
+	{Key: "destination", Value: 1},
+}
+
+// uniqueTransactionMovements removes movements that were saved more than once, keeping the
+// latest, and creates the unique index that keeps it from happening again.
+func uniqueTransactionMovements(ctx context.Context, db *mongo.Database) error {
+	collection := db.Collection("transactions")
+	indexed := bson.M{"instruction_index": bson.M{"$exists": true}}
+
+	if err := deleteDuplicates(ctx, collection, movementKey, indexed); err != nil {
+		return fmt.Errorf("failed to delete duplicate transactions: %v", err)
+	}
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    movementKey,
+		Options: options.Index().SetName("unique_movement").SetUnique(true).SetPartialFilterExpression(indexed),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create unique movement index: %v", err)
+	}
+	return nil
+}
+
+// uniqueTransactionMovementsByAccount replaces the unique movement index, which left out movements
+// derived from balance deltas, with one on the full movementKey. Documents from before version 2
+// have no direction and are left out, as their movements cannot be told apart.
+func uniqueTransactionMovementsByAccount(ctx context.Context, db *mongo.Database) error {
+	collection := db.Collection("transactions")
+	indexed := bson.M{"direction": bson.M{"$exists": true}}
+
+	if _, err := collection.Indexes().DropOne(ctx, "unique_movement"); err != nil && !isNotFound(err) {
+		return fmt.Errorf("failed to drop unique movement index: %v", err)
+	}
+	if err := deleteDuplicates(ctx, collection, movementKey, indexed); err != nil {
+		return fmt.Errorf("failed to delete duplicate transactions: %v", err)
+	}
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    movementKey,
+		Options: options.Index().SetName("unique_movement").SetUnique(true).SetPartialFilterExpression(indexed),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create unique movement index: %v", err)
+	}
+	return nil
+}
+
+// isNotFound reports whether the command failed because its collection or index does not exist.
+func isNotFound(err error) bool {
+	var commandErr mongo.CommandError
+	return errors.As(err, &commandErr) && (commandErr.Name == "NamespaceNotFound" || commandErr.Name == "IndexNotFound")
+}
+
+// uniqueProgramEvents creates the unique index on the position of an event in its transaction.
+// Events saved before they were numbered are left out of it.
+func uniqueProgramEvents(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("program_events").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "hash", Value: 1}, {Key: "event_index", Value: 1}},
+		Options: options.Index().SetName("unique_event").SetUnique(true).SetPartialFilterExpression(bson.M{"event_index": bson.M{"$exists": true}}),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create unique program event index: %v", err)
+	}
+	return nil
+}
+
+// balanceChangeKey identifies the change of an account's balance at a slot.
+var balanceChangeKey = bson.D{
+	{Key: "account", Value: 1},
+	{Key: "slot", Value: 1},
+}
+
+// uniqueBalanceChanges removes balance changes that were saved more than once, keeping the
+// latest, and creates the unique index that keeps it from happening again.
+func uniqueBalanceChanges(ctx context.Context, db *mongo.Database) error {
+	collection := db.Collection("balance_changes")
+	if err := deleteDuplicates(ctx, collection, balanceChangeKey, bson.M{}); err != nil {
+		return fmt.Errorf("failed to delete duplicate balance changes: %v", err)
+	}
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    balanceChangeKey,
+		Options: options.Index().SetName("unique_balance_change").SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create unique balance change index: %v", err)
+	}
+	return nil
+}
+
+// deleteDuplicates deletes all but the latest of the documents matching match that share the
+// values of the key fields.
+func deleteDuplicates(ctx context.Context, collection *mongo.Collection, key bson.D, match bson.M) error {
+	group := bson.M{}
+	for _, field := range key {
+		group[field.Key] = "$" + field.Key
+	}
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$sort", Value: bson.M{"_id": -1}}},
+		{{Key: "$group", Value: bson.M{"_id": group, "ids": bson.M{"$push": "$_id"}, "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	}, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return fmt.Errorf("failed to find duplicates: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var duplicates struct {
+			IDs []primitive.ObjectID `bson:"ids"`
+		}
+		if err := cursor.Decode(&duplicates); err != nil {
+			return fmt.Errorf("failed to decode duplicates: %v", err)
+		}
+		if _, err := collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": duplicates.IDs[1:]}}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
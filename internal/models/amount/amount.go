@@ -0,0 +1,221 @@
+package amount
+
+import (
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Amount is an exact token amount: a whole number of base units and the number of decimals the
+// mint uses. The zero value is zero with no decimals. Amounts are immutable; every operation
+// returns a new value.
+type Amount struct {
+	raw      *big.Int
+	decimals uint8
+}
+
+// New returns an amount of raw base units.
+func New(raw uint64, decimals uint8) Amount {
+	return Amount{raw: new(big.Int).SetUint64(raw), decimals: decimals}
+}
+
+// FromBigInt returns an amount of raw base units. The value is copied.
+func FromBigInt(raw *big.Int, decimals uint8) Amount {
+	if raw == nil {
+		return Amount{decimals: decimals}
+	}
+	return Amount{raw: new(big.Int).Set(raw), decimals: decimals}
+}
+
+// Parse parses a base-10 integer number of base units, as found in RPC token balances.
+func Parse(raw string, decimals uint8) (Amount, error) {
+	value, ok := new(big.Int).SetString(raw, 10)
+	if !ok {
+		return Amount{}, fmt.Errorf("invalid raw amount %q", raw)
+	}
+	return Amount{raw: value, decimals: decimals}, nil
+}
+
+// ParseDecimal parses a decimal string such as "12.5", keeping every digit after the point.
+func ParseDecimal(value string) (Amount, error) {
+	whole, fraction, _ := strings.Cut(value, ".")
+	if len(fraction) > 255 {
+		return Amount{}, fmt.Errorf("too many decimals in %q", value)
+	}
+	raw, ok := new(big.Int).SetString(whole+fraction, 10)
+	if !ok {
+		return Amount{}, fmt.Errorf("invalid decimal amount %q", value)
+	}
+	return Amount{raw: raw, decimals: uint8(len(fraction))}, nil
+}
+
+func (a Amount) value() *big.Int {
+	if a.raw == nil {
+		return new(big.Int)
+	}
+	return a.raw
+}
+
+// Raw returns a copy of the number of base units.
+func (a Amount) Raw() *big.Int {
+	return new(big.Int).Set(a.value())
+}
+
+// RawString returns the number of base units in base 10.
+func (a Amount) RawString() string {
+	return a.value().String()
+}
+
+func (a Amount) Decimals() uint8 {
+	return a.decimals
+}
+
+func (a Amount) Sign() int {
+	return a.value().Sign()
+}
+
+func (a Amount) Abs() Amount {
+	return Amount{raw: new(big.Int).Abs(a.value()), decimals: a.decimals}
+}
+
+func (a Amount) Neg() Amount {
+	return Amount{raw: new(big.Int).Neg(a.value()), decimals: a.decimals}
+}
+
+// Rescale returns the same amount expressed with more decimals. Fewer decimals would lose
+// precision, so the amount is returned unchanged in that case.
+func (a Amount) Rescale(decimals uint8) Amount {
+	if decimals <= a.decimals {
+		return a
+	}
+	scaled := a.mul10(int(decimals - a.decimals))
+	scaled.decimals = decimals
+	return scaled
+}
+
+// mul10 returns the amount multiplied by 10^exponent, keeping its decimals.
+func (a Amount) mul10(exponent int) Amount {
+	factor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exponent)), nil)
+	return Amount{raw: new(big.Int).Mul(a.value(), factor), decimals: a.decimals}
+}
+
+// Add returns a+b, expressed with the larger of the two decimals.
+func (a Amount) Add(b Amount) Amount {
+	a, b = align(a, b)
+	return Amount{raw: new(big.Int).Add(a.value(), b.value()), decimals: a.decimals}
+}
+
+// Sub returns a-b, expressed with the larger of the two decimals.
+func (a Amount) Sub(b Amount) Amount {
+	a, b = align(a, b)
+	return Amount{raw: new(big.Int).Sub(a.value(), b.value()), decimals: a.decimals}
+}
+
+// Cmp compares the values of a and b regardless of their decimals.
+func (a Amount) Cmp(b Amount) int {
+	a, b = align(a, b)
+	return a.value().Cmp(b.value())
+}
+
+// Sum adds the amounts up exactly.
+func Sum(amounts ...Amount) Amount {
+	var total Amount
+	for _, amount := range amounts {
+		total = total.Add(amount)
+	}
+	return total
+}
+
+func align(a, b Amount) (Amount, Amount) {
+	if a.decimals > b.decimals {
+		return a, b.Rescale(a.decimals)
+	}
+	return a.Rescale(b.decimals), b
+}
+
+// String formats the amount exactly, without trailing zeros after the decimal point.
+func (a Amount) String() string {
+	digits := new(big.Int).Abs(a.value()).String()
+	sign := ""
+	if a.Sign() < 0 {
+		sign = "-"
+	}
+	if a.decimals == 0 {
+		return sign + digits
+	}
+
+	decimals := int(a.decimals)
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	whole, fraction := digits[:len(digits)-decimals], strings.TrimRight(digits[len(digits)-decimals:], "0")
+	if fraction == "" {
+		return sign + whole
+	}
+	return sign + whole + "." + fraction
+}
+
+// Decimal128 returns the exact value as a Mongo Decimal128, which holds up to 34 significant digits.
+func (a Amount) Decimal128() (primitive.Decimal128, error) {
+	value, ok := primitive.ParseDecimal128FromBigInt(a.value(), -int(a.decimals))
+	if !ok {
+		return primitive.Decimal128{}, fmt.Errorf("amount %s does not fit in a Decimal128", a.String())
+	}
+	return value, nil
+}
+
+// MarshalBSONValue stores the amount as a Decimal128 so Mongo can aggregate it exactly. Amounts
+// with more than 34 significant digits are stored as null instead of failing the whole document;
+// documents keep the raw base units next to the amount, and those stay authoritative.
+func (a Amount) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	value, err := a.Decimal128()
+	if err != nil {
+		return bsontype.Null, nil, nil
+	}
+	return bson.MarshalValue(value)
+}
+
+// UnmarshalBSONValue reads a Decimal128, and also accepts the doubles and strings written by
+// older schema versions.
+func (a *Amount) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	value := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bsontype.Decimal128:
+		raw, exponent, err := value.Decimal128().BigInt()
+		if err != nil {
+			return fmt.Errorf("invalid decimal amount: %v", err)
+		}
+		if exponent > 0 {
+			*a = FromBigInt(raw, 0).mul10(exponent)
+			return nil
+		}
+		if exponent < -255 {
+			return fmt.Errorf("too many decimals in amount %s", value.Decimal128().String())
+		}
+		*a = FromBigInt(raw, uint8(-exponent))
+		return nil
+	case bsontype.Double:
+		parsed, err := ParseDecimal(strconv.FormatFloat(value.Double(), 'f', -1, 64))
+		if err != nil {
+			return err
+		}
+		*a = parsed
+		return nil
+	case bsontype.String:
+		parsed, err := ParseDecimal(value.StringValue())
+		if err != nil {
+			return err
+		}
+		*a = parsed
+		return nil
+	case bsontype.Null:
+		*a = Amount{}
+		return nil
+	default:
+		return fmt.Errorf("cannot decode %s into an amount", t)
+	}
+}
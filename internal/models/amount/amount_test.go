@@ -0,0 +1,191 @@
+package amount
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"math/big"
+	"testing"
+)
+
+func TestParseAndString(t *testing.T) {
+	tests := []struct {
+		raw      string
+		decimals uint8
+		want     string
+	}{
+		{raw: "0", decimals: 6, want: "0"},
+		{raw: "1", decimals: 9, want: "0.000000001"},
+		{raw: "1500000", decimals: 6, want: "1.5"},
+		{raw: "2000000", decimals: 6, want: "2"},
+		{raw: "-250", decimals: 2, want: "-2.5"},
+		{raw: "42", decimals: 0, want: "42"},
+		{raw: "18446744073709551616000000001", decimals: 9, want: "18446744073709551616.000000001"},
+	}
+
+	for _, test := range tests {
+		a, err := Parse(test.raw, test.decimals)
+		if err != nil {
+			t.Fatalf("Parse(%q, %d) failed: %v", test.raw, test.decimals, err)
+		}
+		if got := a.String(); got != test.want {
+			t.Errorf("Parse(%q, %d).String() = %q, want %q", test.raw, test.decimals, got, test.want)
+		}
+		if got := a.RawString(); got != test.raw {
+			t.Errorf("Parse(%q, %d).RawString() = %q", test.raw, test.decimals, got)
+		}
+		if got := a.Decimals(); got != test.decimals {
+			t.Errorf("Parse(%q, %d).Decimals() = %d", test.raw, test.decimals, got)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, raw := range []string{"", "1.5", "abc", "0x10"} {
+		if _, err := Parse(raw, 6); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", raw)
+		}
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	a, err := ParseDecimal("12.050")
+	if err != nil {
+		t.Fatalf("ParseDecimal failed: %v", err)
+	}
+	if a.RawString() != "12050" || a.Decimals() != 3 {
+		t.Errorf("ParseDecimal(12.050) = %s with %d decimals, want 12050 with 3", a.RawString(), a.Decimals())
+	}
+	if a.String() != "12.05" {
+		t.Errorf("ParseDecimal(12.050).String() = %q, want 12.05", a.String())
+	}
+}
+
+func TestArithmeticAcrossDecimals(t *testing.T) {
+	// 1.5 with 6 decimals and 0.25 with 9 decimals
+	a := New(1500000, 6)
+	b := New(250000000, 9)
+
+	sum := a.Add(b)
+	if sum.String() != "1.75" || sum.Decimals() != 9 {
+		t.Errorf("Add = %s with %d decimals, want 1.75 with 9", sum, sum.Decimals())
+	}
+
+	difference := b.Sub(a)
+	if difference.String() != "-1.25" || difference.Decimals() != 9 {
+		t.Errorf("Sub = %s with %d decimals, want -1.25 with 9", difference, difference.Decimals())
+	}
+
+	if a.Cmp(b) != 1 || b.Cmp(a) != -1 {
+		t.Errorf("Cmp(1.5, 0.25) = %d, Cmp(0.25, 1.5) = %d", a.Cmp(b), b.Cmp(a))
+	}
+	if New(2, 0).Cmp(New(2000, 3)) != 0 {
+		t.Errorf("Cmp(2, 2.000) is not 0")
+	}
+
+	total := Sum(a, b, New(3, 0))
+	if total.String() != "4.75" {
+		t.Errorf("Sum = %s, want 4.75", total)
+	}
+}
+
+func TestZeroValue(t *testing.T) {
+	var zero Amount
+	if zero.String() != "0" || zero.Sign() != 0 {
+		t.Errorf("zero value is %s with sign %d", zero, zero.Sign())
+	}
+	if got := zero.Add(New(5, 1)); got.String() != "0.5" {
+		t.Errorf("0 + 0.5 = %s", got)
+	}
+}
+
+func TestOperationsDoNotMutate(t *testing.T) {
+	raw := big.NewInt(100)
+	a := FromBigInt(raw, 2)
+	raw.SetInt64(7)
+	_ = a.Add(New(1, 2))
+	_ = a.Neg()
+	if a.String() != "1" {
+		t.Errorf("amount changed to %s", a)
+	}
+}
+
+func TestBSONRoundTrip(t *testing.T) {
+	type document struct {
+		Amount Amount `bson:"amount"`
+	}
+
+	for _, a := range []Amount{
+		New(0, 0),
+		New(1, 9),
+		New(123456789, 6),
+		New(1000, 3),
+		New(5, 0).Neg(),
+	} {
+		data, err := bson.Marshal(document{Amount: a})
+		if err != nil {
+			t.Fatalf("Marshal(%s) failed: %v", a, err)
+		}
+		if kind := bson.Raw(data).Lookup("amount").Type; kind != bson.TypeDecimal128 {
+			t.Errorf("amount %s stored as %s, want decimal128", a, kind)
+		}
+
+		var decoded document
+		if err := bson.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("Unmarshal(%s) failed: %v", a, err)
+		}
+		if decoded.Amount.Cmp(a) != 0 || decoded.Amount.Decimals() != a.Decimals() {
+			t.Errorf("round trip of %s with %d decimals gave %s with %d", a, a.Decimals(), decoded.Amount, decoded.Amount.Decimals())
+		}
+	}
+}
+
+func TestBSONDecodesOlderSchemas(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{value: 12.5, want: "12.5"},
+		{value: "0.000001", want: "0.000001"},
+		{value: nil, want: "0"},
+	}
+
+	for _, test := range tests {
+		data, err := bson.Marshal(bson.M{"amount": test.value})
+		if err != nil {
+			t.Fatalf("Marshal(%v) failed: %v", test.value, err)
+		}
+		var decoded struct {
+			Amount Amount `bson:"amount"`
+		}
+		if err := bson.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("Unmarshal(%v) failed: %v", test.value, err)
+		}
+		if got := decoded.Amount.String(); got != test.want {
+			t.Errorf("decoded %v as %s, want %s", test.value, got, test.want)
+		}
+	}
+}
+
+func TestBSONStoresOversizedAmountsAsNull(t *testing.T) {
+	raw := "1234567890123456789012345678901234567890"
+	a, err := Parse(raw, 9)
+	if err != nil {
+		t.Fatalf("Parse(%q) failed: %v", raw, err)
+	}
+	if _, err := a.Decimal128(); err == nil {
+		t.Errorf("Decimal128() of %s succeeded, want an error", a)
+	}
+
+	data, err := bson.Marshal(struct {
+		Amount    Amount `bson:"amount"`
+		RawAmount string `bson:"raw_amount"`
+	}{Amount: a, RawAmount: a.RawString()})
+	if err != nil {
+		t.Fatalf("Marshal(%s) failed: %v", a, err)
+	}
+	if kind := bson.Raw(data).Lookup("amount").Type; kind != bson.TypeNull {
+		t.Errorf("amount %s stored as %s, want null", a, kind)
+	}
+	if got := bson.Raw(data).Lookup("raw_amount").StringValue(); got != raw {
+		t.Errorf("raw_amount stored as %q, want %q", got, raw)
+	}
+}
@@ -1,23 +1,207 @@
 package entity
 
-import "time"
+import (
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/amount"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// TransactionSchemaVersion is the version written to new transaction documents. Version 1
+// documents predate the field and store hex-encoded signatures without on-chain context; the
+// migrations package upgrades their signatures but cannot recover the rest. Version 2 documents
+// store the amount as a float64, version 3 as an exact Decimal128. Version 3 documents upgraded
+// from version 1 have no raw_amount or decimals, as the mint's decimals were never stored.
+const TransactionSchemaVersion = 3
 
 type Transaction struct {
-	Hash        string    `bson:"hash"`
-	Source      string    `bson:"source"`
-	Destination string    `bson:"destination"`
-	Amount      float64   `bson:"amount"`
-	TokenMint   string    `bson:"token_mint"`
-	Timestamp   time.Time `bson:"timestamp"`
+	SchemaVersion int `bson:"schema_version"`
+
+	Hash                  string     `bson:"hash"`
+	Version               string     `bson:"version"`
+	Slot                  uint64     `bson:"slot"`
+	BlockTime             *time.Time `bson:"block_time,omitempty"`
+	TransactionIndex      *int       `bson:"transaction_index,omitempty"`
+	InstructionIndex      *int       `bson:"instruction_index,omitempty"`
+	InnerInstructionIndex *int       `bson:"inner_instruction_index,omitempty"`
+	Fee                   uint64     `bson:"fee"`
+	ComputeUnitsConsumed  *uint64    `bson:"compute_units_consumed,omitempty"`
+	Success               bool       `bson:"success"`
+	Error                 string     `bson:"error,omitempty"`
+	Memos                 []string   `bson:"memos,omitempty"`
+	Commitment            string     `bson:"commitment"`
+
+	Instruction      string          `bson:"instruction"`
+	Direction        enums.Direction `bson:"direction"`
+	Source           string          `bson:"source"`
+	SourceOwner      string          `bson:"source_owner"`
+	Destination      string          `bson:"destination"`
+	DestinationOwner string          `bson:"destination_owner"`
+	Amount           amount.Amount   `bson:"amount"`
+	RawAmount        string          `bson:"raw_amount"`
+	Decimals         uint8           `bson:"decimals"`
+	TokenMint        string          `bson:"token_mint"`
+	Timestamp        time.Time       `bson:"timestamp"`
+}
+
+// ProgramEvent is a generic structured event decoded from a program's IDL, either from one of
+// its instructions or from data it logged. Stake program instructions are stored as program
+// events of the "stake" program too.
+type ProgramEvent struct {
+	Hash                  string                   `bson:"hash"`
+	Slot                  uint64                   `bson:"slot"`
+	BlockTime             *time.Time               `bson:"block_time,omitempty"`
+	InstructionIndex      int                      `bson:"instruction_index"`
+	InnerInstructionIndex *int                     `bson:"inner_instruction_index,omitempty"`
+	EventIndex            int                      `bson:"event_index"`
+	ProgramID             string                   `bson:"program_id"`
+	Program               string                   `bson:"program"`
+	Name                  string                   `bson:"name"`
+	Source                enums.ProgramEventSource `bson:"source"`
+	Accounts              map[string]string        `bson:"accounts,omitempty"`
+	Data                  map[string]any           `bson:"data"`
+	Commitment            string                   `bson:"commitment"`
+	Timestamp             time.Time                `bson:"timestamp"`
+}
+
+// Balance is the current holding of a watched account: the lamports of a wallet, under the native
+// SOL mint, or the token amount of one of its token accounts. Slot is the slot the balance was
+// read at.
+type Balance struct {
+	Account   string        `bson:"_id"`
+	Wallet    string        `bson:"wallet"`
+	Mint      string        `bson:"mint"`
+	Slot      uint64        `bson:"slot"`
+	Amount    amount.Amount `bson:"amount"`
+	RawAmount string        `bson:"raw_amount"`
+	Decimals  uint8         `bson:"decimals"`
+	UpdatedAt time.Time     `bson:"updated_at"`
+}
+
+// BalanceChange records a watched account's balance becoming Amount at Slot. Change is the
+// difference to the previous balance and is unset for the first balance seen of an account.
+type BalanceChange struct {
+	Account   string         `bson:"account"`
+	Wallet    string         `bson:"wallet"`
+	Mint      string         `bson:"mint"`
+	Slot      uint64         `bson:"slot"`
+	Amount    amount.Amount  `bson:"amount"`
+	RawAmount string         `bson:"raw_amount"`
+	Decimals  uint8          `bson:"decimals"`
+	Change    *amount.Amount `bson:"change,omitempty"`
+	Timestamp time.Time      `bson:"timestamp"`
+}
+
+// BackfillJob is a persisted backfill run. Slot jobs cover Range, which is resolved from From and
+// To when only a time window is given; Completed holds the merged parts of it already processed,
+// so a resumed job skips them. Slot jobs with neither catch up from the backfill watermark and
+// resume through its range ledger. Wallet jobs sweep the monitored addresses and resume through
+// their cursors. Progress counts slots for slot jobs and addresses for wallet jobs.
+type BackfillJob struct {
+	ID         primitive.ObjectID      `bson:"_id,omitempty"`
+	Mode       enums.BackfillMode      `bson:"mode"`
+	Status     enums.BackfillJobStatus `bson:"status"`
+	Range      *SlotRange              `bson:"range,omitempty"`
+	From       *time.Time              `bson:"from,omitempty"`
+	To         *time.Time              `bson:"to,omitempty"`
+	Completed  []SlotRange             `bson:"completed,omitempty"`
+	Progress   BackfillProgress        `bson:"progress"`
+	Errors     int64                   `bson:"errors"`
+	LastError  string                  `bson:"last_error,omitempty"`
+	CreatedAt  time.Time               `bson:"created_at"`
+	StartedAt  *time.Time              `bson:"started_at,omitempty"`
+	FinishedAt *time.Time              `bson:"finished_at,omitempty"`
+	UpdatedAt  time.Time               `bson:"updated_at"`
+}
+
+type BackfillProgress struct {
+	Done  uint64 `bson:"done"`
+	Total uint64 `bson:"total"`
+}
+
+// AddressCursor tracks the signature history backfill of one address. Newest is the most recent
+// signature of the last completed sweep, which the next sweep stops at. While a sweep runs,
+// Before is the oldest signature processed so far and SweepNewest the signature it started from.
+type AddressCursor struct {
+	Address     string    `bson:"_id"`
+	Newest      string    `bson:"newest"`
+	SweepNewest string    `bson:"sweep_newest"`
+	Before      string    `bson:"before"`
+	UpdatedAt   time.Time `bson:"updated_at"`
+}
+
+// SlotRange is an inclusive range of slots, such as a backfill chunk.
+type SlotRange struct {
+	Start uint64 `bson:"start"`
+	End   uint64 `bson:"end"`
 }
 
 type EventName uint
 
+const (
+	// RPCRequestEvent is recorded for every call made to an RPC endpoint. Its param is an RPCRequest.
+	RPCRequestEvent EventName = iota + 1
+	// RPCEndpointHealthEvent is recorded whenever an endpoint's health changes. Its param is an
+	// RPCEndpointHealth.
+	RPCEndpointHealthEvent
+	// BackfillJobEvent is recorded whenever a backfill job changes status or makes progress. Its
+	// param is a BackfillJob.
+	BackfillJobEvent
+	// StreamHealthEvent is recorded every second while the WebSocket manager runs. Its param is a
+	// StreamHealth.
+	StreamHealthEvent
+	// StreamDisconnectEvent is recorded whenever the WebSocket connection is lost. Its param is a
+	// StreamDisconnect.
+	StreamDisconnectEvent
+	// StreamNotificationDroppedEvent is recorded for every notification dropped because its
+	// subscription's consumer fell behind. Its param is a StreamNotificationDropped.
+	StreamNotificationDroppedEvent
+)
+
 type Event struct {
 	id     EventName
 	params []interface{}
 }
 
+func NewEvent(id EventName, params ...interface{}) Event {
+	return Event{id: id, params: params}
+}
+
 func (e Event) GetID() EventName {
 	return e.id
 }
+
+func (e Event) GetParams() []interface{} {
+	return e.params
+}
+
+type RPCRequest struct {
+	Endpoint string
+	Method   string
+	Outcome  enums.RPCOutcome
+	Duration time.Duration
+}
+
+// RPCEndpointHealth is the state an endpoint is ranked by. Lower scores are healthier.
+type RPCEndpointHealth struct {
+	Endpoint  string
+	Score     float64
+	Latency   time.Duration
+	ErrorRate float64
+	SlotLag   uint64
+}
+
+// StreamHealth is the state of the live stream. NotificationAge is the time since the last
+// notification, or since the connection came up when none arrived on it yet.
+type StreamHealth struct {
+	Connected       bool
+	NotificationAge time.Duration
+}
+
+type StreamDisconnect struct {
+	Reason enums.StreamDisconnectReason
+}
+
+type StreamNotificationDropped struct {
+	Action enums.SubscriptionAction
+}
@@ -3,16 +3,71 @@ package request
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+	"io"
+	"time"
 )
 
+// TransactionLog is a logsSubscribe notification.
 type TransactionLog struct {
 	Params struct {
 		Result struct {
-			Signature string `json:"signature"`
+			Context struct {
+				Slot uint64 `json:"slot"`
+			} `json:"context"`
+			Value struct {
+				Signature string `json:"signature"`
+			} `json:"value"`
 		} `json:"result"`
 	} `json:"params"`
 }
 
+// SlotUpdate is a slotSubscribe notification. Parent is the slot the new slot builds on, so
+// slots between the previously seen slot and Parent were missed.
+type SlotUpdate struct {
+	Params struct {
+		Result struct {
+			Slot   uint64 `json:"slot"`
+			Parent uint64 `json:"parent"`
+			Root   uint64 `json:"root"`
+		} `json:"result"`
+	} `json:"params"`
+}
+
+// AccountUpdate is an accountSubscribe notification for a subscription with base64 encoding. Data
+// holds the encoded account data followed by the encoding.
+type AccountUpdate struct {
+	Params struct {
+		Result struct {
+			Context struct {
+				Slot uint64 `json:"slot"`
+			} `json:"context"`
+			Value struct {
+				Lamports uint64   `json:"lamports"`
+				Owner    string   `json:"owner"`
+				Data     []string `json:"data"`
+			} `json:"value"`
+		} `json:"result"`
+	} `json:"params"`
+}
+
+func ParseAccountUpdate(message []byte) (*AccountUpdate, error) {
+	var update AccountUpdate
+	if err := json.Unmarshal(message, &update); err != nil {
+		return nil, fmt.Errorf("failed to parse WebSocket account update: %w", err)
+	}
+	return &update, nil
+}
+
+func ParseSlotUpdate(message []byte) (*SlotUpdate, error) {
+	var update SlotUpdate
+	if err := json.Unmarshal(message, &update); err != nil {
+		return nil, fmt.Errorf("failed to parse WebSocket slot update: %w", err)
+	}
+	return &update, nil
+}
+
 func ParseTransactionLog(message []byte) (*TransactionLog, error) {
 	var txLog TransactionLog
 	if err := json.Unmarshal(message, &txLog); err != nil {
@@ -20,3 +75,39 @@ func ParseTransactionLog(message []byte) (*TransactionLog, error) {
 	}
 	return &txLog, nil
 }
+
+// CreateBackfillJob is the body of a backfill job creation request. Slot jobs take either
+// StartSlot and EndSlot or From and To, or neither to catch up from the backfill watermark; wallet
+// jobs take neither.
+type CreateBackfillJob struct {
+	Mode      enums.BackfillMode `json:"mode"`
+	StartSlot *uint64            `json:"start_slot"`
+	EndSlot   *uint64            `json:"end_slot"`
+	From      *time.Time         `json:"from"`
+	To        *time.Time         `json:"to"`
+}
+
+func ParseCreateBackfillJob(body io.Reader) (*CreateBackfillJob, error) {
+	var create CreateBackfillJob
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&create); err != nil {
+		return nil, fmt.Errorf("failed to parse backfill job: %w", err)
+	}
+	if (create.StartSlot == nil) != (create.EndSlot == nil) {
+		return nil, fmt.Errorf("backfill job needs both start_slot and end_slot")
+	}
+	if (create.From == nil) != (create.To == nil) {
+		return nil, fmt.Errorf("backfill job needs both from and to")
+	}
+	return &create, nil
+}
+
+// Job returns the backfill job the request asks for.
+func (c *CreateBackfillJob) Job() *entity.BackfillJob {
+	job := &entity.BackfillJob{Mode: c.Mode, From: c.From, To: c.To}
+	if c.StartSlot != nil {
+		job.Range = &entity.SlotRange{Start: *c.StartSlot, End: *c.EndSlot}
+	}
+	return job
+}
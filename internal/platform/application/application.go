@@ -6,8 +6,15 @@ import (
 	"github.com/delaram-gholampoor-sagha/SOLSniffer/configs"
 	repositoriescontracts "github.com/delaram-gholampoor-sagha/SOLSniffer/internal/contracts/repositories"
 	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/contracts/services"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/migrations"
 	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/platform/monitoring"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/backfillJob"
 	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/backfillTransaction"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/balanceMonitor"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/gapDetector"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/instructionDecoder"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/watchlist"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/transport/httpServer"
 	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/transport/solanaClient"
 
 	log "github.com/delaram-gholampoor-sagha/SOLSniffer/internal/logger"
@@ -36,16 +43,27 @@ type App struct {
 		WebSocketManager *webSocket.Manager
 	}
 
+	HTTPServer *httpServer.Server
+
 	Services struct {
+		InstructionDecoders           *instructionDecoder.Registry
+		Watchlist                     *watchlist.Service
 		TokenProcessor                *tokenTransactionProcessor.Service
 		TransactionMonitor            *transactionMonitor.Service
+		BalanceMonitor                *balanceMonitor.Service
 		TransactionMonitorCoordinator *transactionMonitorCoordinator.Service
 		BackfillTransaction           *backfillTransaction.Service
+		BackfillJobs                  *backfillJob.Service
+		GapDetector                   *gapDetector.Service
 	}
 
 	Repositories struct {
 		Transaction         repositoriescontracts.Transaction
+		ProgramEvent        repositoriescontracts.ProgramEvent
 		BackfillTransaction repositoriescontracts.BackfillTransactionRepository
+		AddressCursor       repositoriescontracts.AddressCursor
+		BackfillJob         repositoriescontracts.BackfillJob
+		Balance             repositoriescontracts.Balance
 	}
 
 	Database struct {
@@ -68,32 +86,51 @@ func NewApplication(ctx context.Context, config *configs.Config) (*App, error) {
 		return nil, err
 	}
 
+	if err := app.runMigrations(ctx); err != nil {
+		return nil, err
+	}
+
 	// Register Repositories
 	app.registerRepositories()
 
 	app.registerMonitoring()
 
+	app.registerSolanaClient()
+
+	if err := app.registerInstructionDecoders(); err != nil {
+		return nil, err
+	}
+
+	app.registerWatchlist()
+
 	// Register TokenTransactionProcessor Service
 	app.registerTokenTransactionProcessor()
 
 	// Register TransactionMonitor Service
 	app.registerTransactionMonitor()
 
+	app.registerBalanceMonitor()
+
 	app.registerBackfillTransaction()
 
-	app.registerSolanaClient()
+	app.registerBackfillJobs()
 
-	// Register TransactionMonitorCoordinator Service
-	if err := app.registerTransactionMonitorCoordinator(); err != nil {
-		return nil, err
-	}
+	app.registerGapDetector()
 
 	// Register WebSocket Manager
 	if err := app.registerWebSocketManager(); err != nil {
 		return nil, err
 	}
 
+	// Register TransactionMonitorCoordinator Service
+	if err := app.registerTransactionMonitorCoordinator(); err != nil {
+		return nil, err
+	}
+
+	app.registerHTTPServer()
+
 	go app.monitorServices(ctx)
+	go app.Client.SolanaClient.MonitorEndpoints(ctx, config.RPC.HealthCheckInterval)
 
 	return app, nil
 }
@@ -132,24 +169,101 @@ func (a *App) registerDatabase() error {
 	return nil
 }
 
+func (a *App) runMigrations(ctx context.Context) error {
+	if err := migrations.Run(ctx, a.Database.Mongo); err != nil {
+		log.Errorf("Failed to run database migrations")
+		return err
+	}
+	log.Infof("Database migrations applied")
+	return nil
+}
+
 func (a *App) registerRepositories() {
 	a.Repositories.Transaction = transaction.NewTransactionRepository(a.Database.Mongo)
 	a.Repositories.BackfillTransaction = transaction.NewMetadataRepository(a.Database.Mongo)
+	a.Repositories.ProgramEvent = transaction.NewProgramEventRepository(a.Database.Mongo)
+	a.Repositories.AddressCursor = transaction.NewAddressCursorRepository(a.Database.Mongo)
+	a.Repositories.BackfillJob = transaction.NewBackfillJobRepository(a.Database.Mongo)
+	a.Repositories.Balance = transaction.NewBalanceRepository(a.Database.Mongo)
 	log.Infof("Repositories registered")
 }
 
 func (a *App) registerSolanaClient() {
-	solanaClient := solanaClient.New()
+	rpcConfig := a.config.RPC
+
+	rateLimit := solanaClient.RateLimit{
+		RequestsPerSecond: rpcConfig.RateLimit.RequestsPerSecond,
+		Methods:           rpcConfig.RateLimit.Methods,
+		BackfillShare:     rpcConfig.RateLimit.BackfillShare,
+	}
+
+	var endpoints []solanaClient.Endpoint
+	for _, endpoint := range rpcConfig.Endpoints {
+		endpointRateLimit := rateLimit
+		if endpoint.RequestsPerSecond > 0 {
+			endpointRateLimit.RequestsPerSecond = endpoint.RequestsPerSecond
+		}
+		endpoints = append(endpoints, solanaClient.Endpoint{
+			Name:      endpoint.Name,
+			URL:       endpoint.URL,
+			Headers:   endpoint.Headers,
+			RateLimit: endpointRateLimit,
+		})
+	}
+	if len(endpoints) == 0 {
+		endpoint := solanaClient.Endpoint{
+			Name:      string(rpcConfig.Cluster),
+			URL:       solanaClient.ClusterEndpoint(rpcConfig.Cluster),
+			Headers:   rpcConfig.Headers,
+			RateLimit: rateLimit,
+		}
+		if rpcConfig.URL != "" {
+			endpoint.Name = "custom"
+			endpoint.URL = rpcConfig.URL
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	batching := solanaClient.Batching{
+		MaxSize: rpcConfig.Batch.MaxSize,
+		Window:  rpcConfig.Batch.Window,
+	}
+
+	solanaClient := solanaClient.New(endpoints, rpcConfig.Timeout, batching, a.Monitoring[RPCMonitoring])
 	a.Client.SolanaClient = solanaClient
 
-	log.Infof("Solana Client registered successfully")
+	log.Infof("Solana Client registered successfully with %d endpoint(s)", len(endpoints))
+}
+
+func (a *App) registerInstructionDecoders() error {
+	registry := instructionDecoder.NewDefaultRegistry()
+
+	if directory := a.config.Decoders.AnchorIDLDirectory; directory != "" {
+		if err := registry.RegisterAnchorIDLs(directory); err != nil {
+			log.Errorf("Failed to load Anchor IDLs from %s", directory)
+			return err
+		}
+		log.Infof("Anchor IDLs loaded from %s", directory)
+	}
+
+	a.Services.InstructionDecoders = registry
+	log.Infof("Instruction decoders registered")
+	return nil
+}
+
+func (a *App) registerWatchlist() {
+	a.Services.Watchlist = watchlist.New(a.config.Services.Wallets, a.config.Services.Tokens)
+	log.Infof("Watchlist registered with %d wallets", len(a.config.Services.Wallets))
 }
 
 func (a *App) registerTokenTransactionProcessor() {
 	a.Services.TokenProcessor = tokenTransactionProcessor.New(
 		a.Repositories.Transaction,
+		a.Repositories.ProgramEvent,
+		a.Client.SolanaClient,
+		a.Services.InstructionDecoders,
 		a.config.Services.Tokens,
-		a.config.Services.Wallets,
+		a.Services.Watchlist,
 	)
 	log.Infof("Token Transaction Processor service registered")
 }
@@ -164,22 +278,57 @@ func (a *App) registerTransactionMonitor() {
 
 }
 
+func (a *App) registerBalanceMonitor() {
+	a.Services.BalanceMonitor = balanceMonitor.New(
+		a.Client.SolanaClient,
+		a.Repositories.Balance,
+		a.Services.Watchlist,
+	)
+	log.Infof("Balance monitor service registered")
+}
+
 func (a *App) registerBackfillTransaction() {
 	backFillTrnasaction := backfillTransaction.New(
 		a.Client.SolanaClient,
 		&a.config.Backfill,
 		a.Repositories.BackfillTransaction,
-		a.Services.TokenProcessor)
+		a.Repositories.AddressCursor,
+		a.Services.TokenProcessor,
+		a.Services.Watchlist,
+		a.config.Services.Tokens)
 
 	a.Services.BackfillTransaction = backFillTrnasaction
 	log.Infof("Transaction Monitor service registered")
 
 }
 
+func (a *App) registerBackfillJobs() {
+	a.Services.BackfillJobs = backfillJob.New(
+		a.Services.BackfillTransaction,
+		a.Repositories.BackfillJob,
+		a.Monitoring[BackfillMonitoring],
+		a.config.Backfill.JobPollInterval,
+	)
+	log.Infof("Backfill job service registered")
+}
+
+func (a *App) registerGapDetector() {
+	a.Services.GapDetector = gapDetector.New(
+		a.Client.SolanaClient,
+		a.Repositories.BackfillTransaction,
+		a.Services.BackfillJobs,
+	)
+	log.Infof("Gap detector service registered")
+}
+
 func (a *App) registerTransactionMonitorCoordinator() error {
 	coordinator := transactionMonitorCoordinator.New(
 		a.Services.TransactionMonitor,
+		a.Services.BalanceMonitor,
+		a.Services.GapDetector,
+		a.Services.Watchlist,
 		a.Client.WebSocketManager,
+		a.config.Coordinator.MaxConcurrency,
 	)
 
 	a.Services.TransactionMonitorCoordinator = coordinator
@@ -188,10 +337,22 @@ func (a *App) registerTransactionMonitorCoordinator() error {
 }
 
 func (a *App) registerWebSocketManager() error {
+	retryConfig := a.config.WebSocket.Retry
+	backoff := webSocket.Backoff{
+		Attempts:    retryConfig.Attempts,
+		Delay:       retryConfig.Delay,
+		MaxDelay:    retryConfig.MaxDelay,
+		Exponential: retryConfig.DelayType == "backoff",
+	}
+	liveness := webSocket.Liveness{
+		PingInterval:        a.config.WebSocket.PingInterval,
+		PongTimeout:         a.config.WebSocket.PongTimeout,
+		NotificationTimeout: a.config.WebSocket.NotificationTimeout,
+	}
 
 	err := retry.Do(
 		func() error {
-			manager, err := webSocket.New(a.config.WebSocket.Scheme, a.config.WebSocket.Host, a.config.WebSocket.Path)
+			manager, err := webSocket.New(a.config.WebSocket.Scheme, a.config.WebSocket.Host, a.config.WebSocket.Path, backoff, liveness, a.Monitoring[StreamMonitoring])
 			if err != nil {
 				return err
 			}
@@ -216,6 +377,11 @@ func (a *App) registerWebSocketManager() error {
 	return nil
 }
 
+func (a *App) registerHTTPServer() {
+	a.HTTPServer = httpServer.New(a.config.App.Addr, a.MonitoringRegistry, a.Services.BackfillJobs)
+	log.Infof("HTTP server registered on %s", a.config.App.Addr)
+}
+
 func (a *App) monitorServices(ctx context.Context) {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
@@ -230,21 +396,23 @@ func (a *App) monitorServices(ctx context.Context) {
 				log.Warnf("MongoDB is not reachable; attempting reconnection...")
 				_ = a.registerDatabase()
 			}
-
-			if !a.Client.WebSocketManager.IsConnected() {
-				log.Warnf("WebSocket disconnected; attempting reconnection...")
-				_ = a.registerWebSocketManager()
-			}
 		}
 	}
 }
 
 const (
-	AppMonitoring = "app_monitoring"
+	AppMonitoring      = "app_monitoring"
+	RPCMonitoring      = "rpc_monitoring"
+	BackfillMonitoring = "backfill_monitoring"
+	StreamMonitoring   = "stream_monitoring"
 )
 
 func (a *App) registerMonitoring() {
+	a.Monitoring = make(map[string]services.Monitoring)
 	a.Monitoring[AppMonitoring] = monitoring.NewPrometheusAppMonitor()
+	a.Monitoring[RPCMonitoring] = monitoring.NewPrometheusRPCMonitor()
+	a.Monitoring[BackfillMonitoring] = monitoring.NewPrometheusBackfillMonitor()
+	a.Monitoring[StreamMonitoring] = monitoring.NewPrometheusStreamMonitor()
 
 	registry := prometheus.NewRegistry()
 	for _, m := range a.Monitoring {
@@ -266,6 +434,17 @@ func (a *App) setupLogger() error {
 func (a *App) Run(ctx context.Context) error {
 	log.Infof("Starting application...")
 
+	if err := a.HTTPServer.Start(); err != nil {
+		log.Errorf("Failed to start HTTP server")
+		return err
+	}
+
+	// Start before the stream so the first slot update is compared with the last one seen
+	if err := a.Services.GapDetector.Start(ctx); err != nil {
+		log.Errorf("Failed to start gap detector")
+		return err
+	}
+
 	err := retry.Do(
 		func() error {
 			if err := a.Services.TransactionMonitorCoordinator.Start(ctx); err != nil {
@@ -287,12 +466,28 @@ func (a *App) Run(ctx context.Context) error {
 	}
 
 	log.Infof("Transaction monitor coordinator started")
-	return nil
+
+	go a.Services.BackfillJobs.Run(ctx)
+	log.Infof("Backfill job runner started")
+
+	// Keep running until the application is asked to stop or the stream is lost for good
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-a.Client.WebSocketManager.Done():
+		log.Errorf("WebSocket connection could not be restored")
+		return a.Client.WebSocketManager.Err()
+	}
 }
 
 func (a *App) Shutdown(ctx context.Context) error {
 	log.Infof("Shutting down application...")
 
+	if err := a.HTTPServer.Shutdown(ctx); err != nil {
+		log.Errorf("Failed to stop HTTP server")
+		return err
+	}
+
 	if err := a.Services.TransactionMonitorCoordinator.Stop(ctx); err != nil {
 		log.Errorf("Failed to stop transaction monitor coordinator")
 		return err
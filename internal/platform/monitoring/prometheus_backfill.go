@@ -0,0 +1,108 @@
+package monitoring
+
+import (
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// backfillJobStatuses are the statuses a job is labelled with until it finishes.
+var backfillJobStatuses = []enums.BackfillJobStatus{
+	enums.BackfillJobPending,
+	enums.BackfillJobRunning,
+	enums.BackfillJobPaused,
+}
+
+// PrometheusBackfillMonitor exports the status and progress of backfill jobs. The series of a job
+// are dropped once it reaches a final status, so only queued, running and paused jobs are
+// labelled; finished jobs are counted by mode and status instead.
+type PrometheusBackfillMonitor struct {
+	registry *prometheus.Registry
+
+	status   *prometheus.GaugeVec
+	done     *prometheus.GaugeVec
+	total    *prometheus.GaugeVec
+	failures *prometheus.GaugeVec
+	finished *prometheus.CounterVec
+}
+
+func NewPrometheusBackfillMonitor() *PrometheusBackfillMonitor {
+	monitor := &PrometheusBackfillMonitor{
+		registry: prometheus.NewRegistry(),
+		status: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "solsniffer_backfill_job_status",
+			Help: "1 for the current status of each backfill job.",
+		}, []string{"job", "mode", "status"}),
+		done: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "solsniffer_backfill_job_progress_done",
+			Help: "Slots or addresses the backfill job has processed.",
+		}, []string{"job", "mode"}),
+		total: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "solsniffer_backfill_job_progress_total",
+			Help: "Slots or addresses the backfill job covers.",
+		}, []string{"job", "mode"}),
+		failures: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "solsniffer_backfill_job_errors",
+			Help: "Chunks or addresses the backfill job failed to process.",
+		}, []string{"job", "mode"}),
+		finished: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "solsniffer_backfill_jobs_finished_total",
+			Help: "Backfill jobs that completed, failed or were cancelled.",
+		}, []string{"mode", "status"}),
+	}
+
+	monitor.registry.MustRegister(
+		monitor.status,
+		monitor.done,
+		monitor.total,
+		monitor.failures,
+		monitor.finished,
+	)
+	return monitor
+}
+
+func (p *PrometheusBackfillMonitor) GetRegistry() *prometheus.Registry {
+	return p.registry
+}
+
+func (p *PrometheusBackfillMonitor) Record(event entity.Event) {
+	params := event.GetParams()
+
+	switch event.GetID() {
+	case entity.BackfillJobEvent:
+		job, ok := firstParam[entity.BackfillJob](params)
+		if !ok {
+			break
+		}
+		id, mode := job.ID.Hex(), string(job.Mode)
+		if job.Status.IsFinal() {
+			if p.forget(id) {
+				p.finished.WithLabelValues(mode, string(job.Status)).Inc()
+			}
+			return
+		}
+		for _, status := range backfillJobStatuses {
+			value := 0.0
+			if status == job.Status {
+				value = 1
+			}
+			p.status.WithLabelValues(id, mode, string(status)).Set(value)
+		}
+		p.done.WithLabelValues(id, mode).Set(float64(job.Progress.Done))
+		p.total.WithLabelValues(id, mode).Set(float64(job.Progress.Total))
+		p.failures.WithLabelValues(id, mode).Set(float64(job.Errors))
+		return
+	}
+	log.Errorf("prometheus backfill monitoring: invalid event id [%d]", event.GetID())
+}
+
+// forget deletes the series of the job and reports whether it had any.
+func (p *PrometheusBackfillMonitor) forget(id string) bool {
+	labels := prometheus.Labels{"job": id}
+	deleted := p.status.DeletePartialMatch(labels)
+	p.done.DeletePartialMatch(labels)
+	p.total.DeletePartialMatch(labels)
+	p.failures.DeletePartialMatch(labels)
+	return deleted > 0
+}
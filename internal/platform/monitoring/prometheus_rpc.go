@@ -0,0 +1,99 @@
+package monitoring
+
+import (
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// PrometheusRPCMonitor exports per-endpoint RPC request and health metrics.
+type PrometheusRPCMonitor struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	score           *prometheus.GaugeVec
+	latency         *prometheus.GaugeVec
+	errorRate       *prometheus.GaugeVec
+	slotLag         *prometheus.GaugeVec
+}
+
+func NewPrometheusRPCMonitor() *PrometheusRPCMonitor {
+	monitor := &PrometheusRPCMonitor{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "solsniffer_rpc_requests_total",
+			Help: "RPC calls by endpoint, method and outcome.",
+		}, []string{"endpoint", "method", "outcome"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "solsniffer_rpc_request_duration_seconds",
+			Help:    "RPC call latency by endpoint and method.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+		}, []string{"endpoint", "method"}),
+		score: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "solsniffer_rpc_endpoint_health_score",
+			Help: "Health score calls are routed by; lower is healthier.",
+		}, []string{"endpoint"}),
+		latency: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "solsniffer_rpc_endpoint_latency_seconds",
+			Help: "Moving average of the endpoint's call latency.",
+		}, []string{"endpoint"}),
+		errorRate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "solsniffer_rpc_endpoint_error_rate",
+			Help: "Moving average of the share of failed calls.",
+		}, []string{"endpoint"}),
+		slotLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "solsniffer_rpc_endpoint_slot_lag",
+			Help: "Slots the endpoint is behind the most advanced endpoint.",
+		}, []string{"endpoint"}),
+	}
+
+	monitor.registry.MustRegister(
+		monitor.requests,
+		monitor.requestDuration,
+		monitor.score,
+		monitor.latency,
+		monitor.errorRate,
+		monitor.slotLag,
+	)
+	return monitor
+}
+
+func (p *PrometheusRPCMonitor) GetRegistry() *prometheus.Registry {
+	return p.registry
+}
+
+func (p *PrometheusRPCMonitor) Record(event entity.Event) {
+	params := event.GetParams()
+
+	switch event.GetID() {
+	case entity.RPCRequestEvent:
+		request, ok := firstParam[entity.RPCRequest](params)
+		if !ok {
+			break
+		}
+		p.requests.WithLabelValues(request.Endpoint, request.Method, string(request.Outcome)).Inc()
+		p.requestDuration.WithLabelValues(request.Endpoint, request.Method).Observe(request.Duration.Seconds())
+		return
+	case entity.RPCEndpointHealthEvent:
+		health, ok := firstParam[entity.RPCEndpointHealth](params)
+		if !ok {
+			break
+		}
+		p.score.WithLabelValues(health.Endpoint).Set(health.Score)
+		p.latency.WithLabelValues(health.Endpoint).Set(health.Latency.Seconds())
+		p.errorRate.WithLabelValues(health.Endpoint).Set(health.ErrorRate)
+		p.slotLag.WithLabelValues(health.Endpoint).Set(float64(health.SlotLag))
+		return
+	}
+	log.Errorf("prometheus rpc monitoring: invalid event id [%d]", event.GetID())
+}
+
+func firstParam[T any](params []interface{}) (T, bool) {
+	var zero T
+	if len(params) == 0 {
+		return zero, false
+	}
+	value, ok := params[0].(T)
+	return value, ok
+}
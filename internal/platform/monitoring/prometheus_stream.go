@@ -0,0 +1,85 @@
+package monitoring
+
+import (
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// PrometheusStreamMonitor exports the health of the WebSocket stream.
+type PrometheusStreamMonitor struct {
+	registry *prometheus.Registry
+
+	connected       prometheus.Gauge
+	notificationAge prometheus.Gauge
+	disconnects     *prometheus.CounterVec
+	dropped         *prometheus.CounterVec
+}
+
+func NewPrometheusStreamMonitor() *PrometheusStreamMonitor {
+	monitor := &PrometheusStreamMonitor{
+		registry: prometheus.NewRegistry(),
+		connected: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "solsniffer_stream_connected",
+			Help: "1 while the WebSocket stream is connected and subscribed.",
+		}),
+		notificationAge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "solsniffer_stream_notification_age_seconds",
+			Help: "Time since the stream delivered its last notification.",
+		}),
+		disconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "solsniffer_stream_disconnects_total",
+			Help: "WebSocket connections lost, by reason.",
+		}, []string{"reason"}),
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "solsniffer_stream_notifications_dropped_total",
+			Help: "Notifications dropped because their consumer fell behind, by subscription.",
+		}, []string{"subscription"}),
+	}
+
+	monitor.registry.MustRegister(
+		monitor.connected,
+		monitor.notificationAge,
+		monitor.disconnects,
+		monitor.dropped,
+	)
+	return monitor
+}
+
+func (p *PrometheusStreamMonitor) GetRegistry() *prometheus.Registry {
+	return p.registry
+}
+
+func (p *PrometheusStreamMonitor) Record(event entity.Event) {
+	params := event.GetParams()
+
+	switch event.GetID() {
+	case entity.StreamHealthEvent:
+		health, ok := firstParam[entity.StreamHealth](params)
+		if !ok {
+			break
+		}
+		connected := 0.0
+		if health.Connected {
+			connected = 1
+		}
+		p.connected.Set(connected)
+		p.notificationAge.Set(health.NotificationAge.Seconds())
+		return
+	case entity.StreamDisconnectEvent:
+		disconnect, ok := firstParam[entity.StreamDisconnect](params)
+		if !ok {
+			break
+		}
+		p.disconnects.WithLabelValues(string(disconnect.Reason)).Inc()
+		return
+	case entity.StreamNotificationDroppedEvent:
+		dropped, ok := firstParam[entity.StreamNotificationDropped](params)
+		if !ok {
+			break
+		}
+		p.dropped.WithLabelValues(string(dropped.Action)).Inc()
+		return
+	}
+	log.Errorf("prometheus stream monitoring: invalid event id [%d]", event.GetID())
+}
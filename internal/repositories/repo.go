@@ -6,11 +6,17 @@ import (
 )
 
 type Repositories struct {
-	TransactionRepository *transaction.TransactionRepository
+	TransactionRepository   *transaction.TransactionRepository
+	ProgramEventRepository  *transaction.ProgramEventRepository
+	AddressCursorRepository *transaction.AddressCursorRepository
+	BackfillJobRepository   *transaction.BackfillJobRepository
 }
 
 func NewRepositories(db *mongo.Client) *Repositories {
 	return &Repositories{
-		TransactionRepository: transaction.NewTransactionRepository(db),
+		TransactionRepository:   transaction.NewTransactionRepository(db),
+		ProgramEventRepository:  transaction.NewProgramEventRepository(db),
+		AddressCursorRepository: transaction.NewAddressCursorRepository(db),
+		BackfillJobRepository:   transaction.NewBackfillJobRepository(db),
 	}
 }
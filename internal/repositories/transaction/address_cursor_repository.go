@@ -0,0 +1,40 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type AddressCursorRepository struct {
+	collection *mongo.Collection
+}
+
+func NewAddressCursorRepository(db *mongo.Client) *AddressCursorRepository {
+	return &AddressCursorRepository{
+		collection: db.Database("solsniffer").Collection("backfill_cursors"),
+	}
+}
+
+// Get retrieves the cursor of an address, or an empty one if its history was never backfilled.
+func (r *AddressCursorRepository) Get(ctx context.Context, address string) (*entity.AddressCursor, error) {
+	cursor := &entity.AddressCursor{Address: address}
+	err := r.collection.FindOne(ctx, bson.M{"_id": address}).Decode(cursor)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("failed to get backfill cursor of %s: %v", address, err)
+	}
+	return cursor, nil
+}
+
+func (r *AddressCursorRepository) Save(ctx context.Context, cursor *entity.AddressCursor) error {
+	cursor.UpdatedAt = time.Now()
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": cursor.Address}, cursor, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to save backfill cursor of %s: %v", cursor.Address, err)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type BackfillJobRepository struct {
+	collection *mongo.Collection
+}
+
+func NewBackfillJobRepository(db *mongo.Client) *BackfillJobRepository {
+	return &BackfillJobRepository{
+		collection: db.Database("solsniffer").Collection("backfill_jobs"),
+	}
+}
+
+// Create stores a new job and sets its ID.
+func (r *BackfillJobRepository) Create(ctx context.Context, job *entity.BackfillJob) error {
+	now := time.Now()
+	job.CreatedAt = now
+	job.UpdatedAt = now
+
+	result, err := r.collection.InsertOne(ctx, job)
+	if err != nil {
+		return fmt.Errorf("failed to create backfill job: %v", err)
+	}
+	job.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *BackfillJobRepository) Get(ctx context.Context, id primitive.ObjectID) (*entity.BackfillJob, error) {
+	var job entity.BackfillJob
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&job); err != nil {
+		return nil, fmt.Errorf("failed to get backfill job %s: %w", id.Hex(), err)
+	}
+	return &job, nil
+}
+
+// List retrieves every job, oldest first.
+func (r *BackfillJobRepository) List(ctx context.Context) ([]entity.BackfillJob, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backfill jobs: %v", err)
+	}
+
+	var jobs []entity.BackfillJob
+	if err := cursor.All(ctx, &jobs); err != nil {
+		return nil, fmt.Errorf("failed to decode backfill jobs: %v", err)
+	}
+	return jobs, nil
+}
+
+// Transition moves the job to the status to if it is in one of the statuses from.
+func (r *BackfillJobRepository) Transition(ctx context.Context, id primitive.ObjectID, from []enums.BackfillJobStatus, to enums.BackfillJobStatus) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "status": bson.M{"$in": from}},
+		bson.M{"$set": bson.M{"status": to, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to move backfill job %s to %s: %v", id.Hex(), to, err)
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// ClaimPending marks the oldest pending job as running and returns it, or nil if there is none.
+// A job resumed after failing starts without its previous finish time.
+func (r *BackfillJobRepository) ClaimPending(ctx context.Context) (*entity.BackfillJob, error) {
+	now := time.Now()
+	var job entity.BackfillJob
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{"status": enums.BackfillJobPending},
+		bson.A{bson.M{"$set": bson.M{
+			"status":     enums.BackfillJobRunning,
+			"started_at": bson.M{"$ifNull": bson.A{"$started_at", now}},
+			"updated_at": now,
+		}}, bson.M{"$unset": "finished_at"}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "created_at", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim pending backfill job: %v", err)
+	}
+	return &job, nil
+}
+
+// RequeueRunning moves jobs left running by a previous process back to pending.
+func (r *BackfillJobRepository) RequeueRunning(ctx context.Context) (int64, error) {
+	result, err := r.collection.UpdateMany(ctx,
+		bson.M{"status": enums.BackfillJobRunning},
+		bson.M{"$set": bson.M{"status": enums.BackfillJobPending, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue running backfill jobs: %v", err)
+	}
+	return result.ModifiedCount, nil
+}
+
+// SaveProgress stores the range and progress of a job without touching its status, so chunks
+// that finish after a pause are kept as well.
+func (r *BackfillJobRepository) SaveProgress(ctx context.Context, job *entity.BackfillJob) error {
+	job.UpdatedAt = time.Now()
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": job.ID},
+		bson.M{"$set": bson.M{
+			"range":      job.Range,
+			"completed":  job.Completed,
+			"progress":   job.Progress,
+			"errors":     job.Errors,
+			"last_error": job.LastError,
+			"updated_at": job.UpdatedAt,
+		}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save progress of backfill job %s: %v", job.ID.Hex(), err)
+	}
+	return nil
+}
+
+// Finish stores the final status and progress of a running job. It reports false if the job was
+// paused or cancelled in the meantime, in which case that status is kept.
+func (r *BackfillJobRepository) Finish(ctx context.Context, job *entity.BackfillJob) (bool, error) {
+	now := time.Now()
+	job.UpdatedAt = now
+	job.FinishedAt = &now
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": job.ID, "status": enums.BackfillJobRunning},
+		bson.M{"$set": bson.M{
+			"status":      job.Status,
+			"range":       job.Range,
+			"completed":   job.Completed,
+			"progress":    job.Progress,
+			"errors":      job.Errors,
+			"last_error":  job.LastError,
+			"finished_at": job.FinishedAt,
+			"updated_at":  job.UpdatedAt,
+		}},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to finish backfill job %s: %v", job.ID.Hex(), err)
+	}
+	return result.MatchedCount > 0, nil
+}
@@ -3,47 +3,142 @@ package transaction
 import (
 	"context"
 	"fmt"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// MetadataRepository keeps the backfill watermark and the ledger of slot ranges completed beyond
+// it, and the last slot the live stream saw. The watermark is contiguous: every slot up to it was
+// processed.
 type MetadataRepository struct {
 	collection *mongo.Collection
+	ranges     *mongo.Collection
 }
 
 func NewMetadataRepository(db *mongo.Client) *MetadataRepository {
 	return &MetadataRepository{
 		collection: db.Database("solsniffer").Collection("metadata"),
+		ranges:     db.Database("solsniffer").Collection("backfill_ranges"),
 	}
 }
 
-// GetLastProcessedBlock retrieves the last processed block height from the metadata collection.
-func (r *MetadataRepository) GetLastProcessedBlock(ctx context.Context) (int64, error) {
+// GetLastProcessedSlot retrieves the slot backfill has processed everything up to.
+func (r *MetadataRepository) GetLastProcessedSlot(ctx context.Context) (uint64, error) {
 	var result struct {
-		BlockHeight int64 `bson:"block_height"`
+		Slot uint64 `bson:"slot"`
 	}
-	err := r.collection.FindOne(ctx, bson.M{"_id": "last_processed_block"}).Decode(&result)
+	err := r.collection.FindOne(ctx, bson.M{"_id": "last_processed_slot"}).Decode(&result)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return 0, nil // Return 0 if no document is found (meaning no block has been processed yet)
+			return 0, nil // Return 0 if no document is found (meaning no slot has been processed yet)
 		}
-		return 0, fmt.Errorf("failed to get last processed block: %v", err)
+		return 0, fmt.Errorf("failed to get last processed slot: %v", err)
 	}
-	return result.BlockHeight, nil
+	return result.Slot, nil
 }
 
-// UpdateLastProcessedBlock updates the last processed block height.
-func (r *MetadataRepository) UpdateLastProcessedBlock(ctx context.Context, blockHeight int64) error {
+// UpdateLastProcessedSlot updates the last processed slot.
+func (r *MetadataRepository) UpdateLastProcessedSlot(ctx context.Context, slot uint64) error {
 	_, err := r.collection.UpdateOne(
 		ctx,
-		bson.M{"_id": "last_processed_block"},
-		bson.M{"$set": bson.M{"block_height": blockHeight}},
+		bson.M{"_id": "last_processed_slot"},
+		bson.M{"$set": bson.M{"slot": slot}},
 		// Create the document if it doesn't exist
 		options.Update().SetUpsert(true),
 	)
 	if err != nil {
-		return fmt.Errorf("failed to update last processed block: %v", err)
+		return fmt.Errorf("failed to update last processed slot: %v", err)
+	}
+	return nil
+}
+
+// GetCompletedSlotRanges retrieves the ranges completed beyond the watermark, ordered by start.
+func (r *MetadataRepository) GetCompletedSlotRanges(ctx context.Context) ([]entity.SlotRange, error) {
+	cursor, err := r.ranges.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "start", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get completed slot ranges: %v", err)
+	}
+
+	var ranges []entity.SlotRange
+	if err := cursor.All(ctx, &ranges); err != nil {
+		return nil, fmt.Errorf("failed to decode completed slot ranges: %v", err)
+	}
+	return ranges, nil
+}
+
+// CompleteSlotRange records a processed range and advances the watermark over every range that
+// now connects to it. It returns the resulting watermark.
+func (r *MetadataRepository) CompleteSlotRange(ctx context.Context, slotRange entity.SlotRange) (uint64, error) {
+	if _, err := r.ranges.InsertOne(ctx, slotRange); err != nil {
+		return 0, fmt.Errorf("failed to record completed slot range: %v", err)
+	}
+
+	for {
+		watermark, err := r.GetLastProcessedSlot(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		// The next range either starts right after the watermark or overlaps it.
+		var next entity.SlotRange
+		err = r.ranges.FindOne(ctx,
+			bson.M{"start": bson.M{"$lte": watermark + 1}, "end": bson.M{"$gt": watermark}},
+			options.FindOne().SetSort(bson.D{{Key: "end", Value: -1}}),
+		).Decode(&next)
+		if err == mongo.ErrNoDocuments {
+			return watermark, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to find next completed slot range: %v", err)
+		}
+
+		// Only move the watermark from the value just read, so concurrent callers cannot move it
+		// back. When another caller moved it first the upsert collides with the existing document.
+		_, err = r.collection.UpdateOne(ctx,
+			bson.M{"_id": "last_processed_slot", "slot": watermark},
+			bson.M{"$set": bson.M{"slot": next.End}},
+			options.Update().SetUpsert(true),
+		)
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to advance last processed slot: %v", err)
+		}
+
+		if _, err := r.ranges.DeleteMany(ctx, bson.M{"end": bson.M{"$lte": next.End}}); err != nil {
+			return 0, fmt.Errorf("failed to prune completed slot ranges: %v", err)
+		}
+	}
+}
+
+// GetLastStreamSlot retrieves the last slot seen on the live stream, or 0 if none was recorded.
+func (r *MetadataRepository) GetLastStreamSlot(ctx context.Context) (uint64, error) {
+	var result struct {
+		Slot uint64 `bson:"slot"`
+	}
+	err := r.collection.FindOne(ctx, bson.M{"_id": "last_stream_slot"}).Decode(&result)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get last stream slot: %v", err)
+	}
+	return result.Slot, nil
+}
+
+// UpdateLastStreamSlot records the last slot seen on the live stream. It never moves backwards.
+func (r *MetadataRepository) UpdateLastStreamSlot(ctx context.Context, slot uint64) error {
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": "last_stream_slot"},
+		bson.M{"$max": bson.M{"slot": slot}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update last stream slot: %v", err)
 	}
 	return nil
 }
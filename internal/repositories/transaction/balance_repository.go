@@ -0,0 +1,66 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/amount"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BalanceRepository keeps the current balance of each watched account in balances and the history
+// of its changes in balance_changes.
+type BalanceRepository struct {
+	balances *mongo.Collection
+	changes  *mongo.Collection
+}
+
+func NewBalanceRepository(db *mongo.Client) *BalanceRepository {
+	database := db.Database("solsniffer")
+	return &BalanceRepository{
+		balances: database.Collection("balances"),
+		changes:  database.Collection("balance_changes"),
+	}
+}
+
+// Get retrieves the current balance of an account, or nil if none was recorded.
+func (r *BalanceRepository) Get(ctx context.Context, account string) (*entity.Balance, error) {
+	var balance entity.Balance
+	err := r.balances.FindOne(ctx, bson.M{"_id": account}).Decode(&balance)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance of %s: %v", account, err)
+	}
+	// Amounts too large for a Decimal128 are stored as null, so the raw amount is read instead.
+	if parsed, err := amount.Parse(balance.RawAmount, balance.Decimals); err == nil {
+		balance.Amount = parsed
+	}
+	return &balance, nil
+}
+
+// Save records a balance change and makes the balance current, unless a balance of a later slot
+// is already current. The change is upserted on its account and slot, so saving it again after a
+// failure replaces it instead of adding it twice.
+func (r *BalanceRepository) Save(ctx context.Context, balance *entity.Balance, change *entity.BalanceChange) error {
+	changeFilter := bson.M{"account": change.Account, "slot": change.Slot}
+	_, err := r.changes.ReplaceOne(ctx, changeFilter, change, options.Replace().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// A concurrent save inserted it first; this time the filter matches it
+		_, err = r.changes.ReplaceOne(ctx, changeFilter, change, options.Replace().SetUpsert(true))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save balance change of %s: %v", change.Account, err)
+	}
+
+	// A later balance keeps the filter from matching, and the upsert then collides with it
+	filter := bson.M{"_id": balance.Account, "slot": bson.M{"$lte": balance.Slot}}
+	_, err = r.balances.ReplaceOne(ctx, filter, balance, options.Replace().SetUpsert(true))
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("failed to save balance of %s: %v", balance.Account, err)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ProgramEventRepository struct {
+	collection *mongo.Collection
+}
+
+func NewProgramEventRepository(db *mongo.Client) *ProgramEventRepository {
+	return &ProgramEventRepository{
+		collection: db.Database("solsniffer").Collection("program_events"),
+	}
+}
+
+// Save upserts the event on its position among the transaction's events, so processing a
+// transaction again replaces its events instead of adding them twice.
+func (r *ProgramEventRepository) Save(ctx context.Context, event *entity.ProgramEvent) error {
+	filter := bson.M{"hash": event.Hash, "event_index": event.EventIndex}
+	_, err := r.collection.ReplaceOne(ctx, filter, event, options.Replace().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// A concurrent save inserted it first; this time the filter matches it
+		_, err = r.collection.ReplaceOne(ctx, filter, event, options.Replace().SetUpsert(true))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save program event: %v", err)
+	}
+	return nil
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type TransactionRepository struct {
@@ -17,8 +19,25 @@ func NewTransactionRepository(db *mongo.Client) *TransactionRepository {
 	}
 }
 
+// Save upserts the movement on the instruction that made it and the accounts it moved between, so
+// processing a transaction again, for example from overlapping gap or backfill jobs, replaces the
+// movement instead of adding it twice. Movements derived from balance deltas have no instruction
+// and are told apart by the wallet, which is their source or destination.
 func (r *TransactionRepository) Save(ctx context.Context, transaction *entity.Transaction) error {
-	_, err := r.collection.InsertOne(ctx, transaction)
+	filter := bson.M{
+		"hash":                    transaction.Hash,
+		"instruction_index":       transaction.InstructionIndex,
+		"inner_instruction_index": transaction.InnerInstructionIndex,
+		"direction":               transaction.Direction,
+		"token_mint":              transaction.TokenMint,
+		"source":                  transaction.Source,
+		"destination":             transaction.Destination,
+	}
+	_, err := r.collection.ReplaceOne(ctx, filter, transaction, options.Replace().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// A concurrent save inserted it first; this time the filter matches it
+		_, err = r.collection.ReplaceOne(ctx, filter, transaction, options.Replace().SetUpsert(true))
+	}
 	if err != nil {
 		return fmt.Errorf("failed to save transaction: %v", err)
 	}
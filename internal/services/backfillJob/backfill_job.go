@@ -0,0 +1,368 @@
+package backfillJob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/contracts/repositories"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/contracts/services"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
+	log "github.com/delaram-gholampoor-sagha/SOLSniffer/internal/logger"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/backfillTransaction"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"sync"
+	"time"
+)
+
+// Service runs persisted backfill jobs one at a time. Operators create, pause, resume and cancel
+// jobs through the HTTP API, which changes the job documents, so a status changed directly in
+// Mongo is honoured as well: the running job's status is polled and the job stops once it is no
+// longer running.
+type Service struct {
+	backfillService *backfillTransaction.Service
+	jobRepo         repositories.BackfillJob
+	monitor         services.Monitoring
+	pollInterval    time.Duration
+
+	mu      sync.Mutex
+	running primitive.ObjectID
+	stop    context.CancelFunc
+}
+
+func New(backfillService *backfillTransaction.Service, jobRepo repositories.BackfillJob, monitor services.Monitoring, pollInterval time.Duration) *Service {
+	return &Service{
+		backfillService: backfillService,
+		jobRepo:         jobRepo,
+		monitor:         monitor,
+		pollInterval:    pollInterval,
+	}
+}
+
+// Create validates the job and queues it. Slot jobs take a slot range, a time window, or neither
+// to catch up from the backfill watermark.
+func (s *Service) Create(ctx context.Context, job *entity.BackfillJob) error {
+	if err := validate(job); err != nil {
+		return fmt.Errorf("%w: %v", services.ErrInvalidBackfillJob, err)
+	}
+
+	job.Status = enums.BackfillJobPending
+	job.Completed = nil
+	job.Progress = entity.BackfillProgress{}
+	if err := s.jobRepo.Create(ctx, job); err != nil {
+		return err
+	}
+
+	log.Infof("Backfill job %s created", job.ID.Hex())
+	s.record(*job)
+	return nil
+}
+
+func validate(job *entity.BackfillJob) error {
+	switch job.Mode {
+	case enums.BackfillSlots:
+		switch {
+		case job.Range != nil:
+			if job.Range.Start > job.Range.End {
+				return fmt.Errorf("backfill job range start %d is after its end %d", job.Range.Start, job.Range.End)
+			}
+		case job.From != nil && job.To != nil:
+			if !job.From.Before(*job.To) {
+				return fmt.Errorf("backfill job window start %s must be before its end %s", job.From, job.To)
+			}
+		case job.From != nil || job.To != nil:
+			return fmt.Errorf("backfill job window needs both a start and an end")
+		}
+	case enums.BackfillWallets:
+		if job.Range != nil || job.From != nil || job.To != nil {
+			return fmt.Errorf("wallet backfill jobs cover the whole history and take no range")
+		}
+	default:
+		return fmt.Errorf("invalid backfill job mode %q", job.Mode)
+	}
+	return nil
+}
+
+func (s *Service) Get(ctx context.Context, id primitive.ObjectID) (*entity.BackfillJob, error) {
+	job, err := s.jobRepo.Get(ctx, id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("%w: %s", services.ErrBackfillJobNotFound, id.Hex())
+	}
+	return job, err
+}
+
+func (s *Service) List(ctx context.Context) ([]entity.BackfillJob, error) {
+	return s.jobRepo.List(ctx)
+}
+
+// Pause stops a queued or running job; Resume queues it again and it continues where it stopped.
+func (s *Service) Pause(ctx context.Context, id primitive.ObjectID) error {
+	return s.transition(ctx, id, []enums.BackfillJobStatus{enums.BackfillJobPending, enums.BackfillJobRunning}, enums.BackfillJobPaused)
+}
+
+// Resume queues a paused job, or retries the parts a failed job could not process.
+func (s *Service) Resume(ctx context.Context, id primitive.ObjectID) error {
+	return s.transition(ctx, id, []enums.BackfillJobStatus{enums.BackfillJobPaused, enums.BackfillJobFailed}, enums.BackfillJobPending)
+}
+
+// Cancel stops a job for good.
+func (s *Service) Cancel(ctx context.Context, id primitive.ObjectID) error {
+	return s.transition(ctx, id, []enums.BackfillJobStatus{enums.BackfillJobPending, enums.BackfillJobRunning, enums.BackfillJobPaused}, enums.BackfillJobCancelled)
+}
+
+func (s *Service) transition(ctx context.Context, id primitive.ObjectID, from []enums.BackfillJobStatus, to enums.BackfillJobStatus) error {
+	ok, err := s.jobRepo.Transition(ctx, id, from, to)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if _, err := s.Get(ctx, id); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: backfill job %s cannot be moved to %s from its current status", services.ErrBackfillJobStatus, id.Hex(), to)
+	}
+	log.Infof("Backfill job %s moved to %s", id.Hex(), to)
+
+	if to != enums.BackfillJobPending {
+		s.mu.Lock()
+		if s.running == id && s.stop != nil {
+			s.stop()
+		}
+		s.mu.Unlock()
+	}
+
+	if job, err := s.jobRepo.Get(ctx, id); err == nil {
+		s.record(*job)
+	}
+	return nil
+}
+
+// Run requeues the jobs a previous process left running, then runs pending jobs oldest first
+// until ctx is done.
+func (s *Service) Run(ctx context.Context) {
+	requeued, err := s.jobRepo.RequeueRunning(ctx)
+	if err != nil {
+		log.Errorf("Failed to requeue interrupted backfill jobs: %v", err)
+	} else if requeued > 0 {
+		log.Infof("Requeued %d interrupted backfill jobs", requeued)
+	}
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			job, err := s.jobRepo.ClaimPending(ctx)
+			if err != nil {
+				log.Errorf("Failed to claim backfill job: %v", err)
+				break
+			}
+			if job == nil {
+				break
+			}
+			s.runJob(ctx, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Infof("Backfill job runner stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) runJob(ctx context.Context, job *entity.BackfillJob) {
+	jobCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	s.mu.Lock()
+	s.running, s.stop = job.ID, stop
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.running, s.stop = primitive.NilObjectID, nil
+		s.mu.Unlock()
+	}()
+
+	go s.watch(jobCtx, job.ID, stop)
+
+	log.Infof("Backfill job %s started", job.ID.Hex())
+	s.record(*job)
+
+	var err error
+	switch {
+	case job.Mode == enums.BackfillWallets:
+		err = s.runWalletJob(jobCtx, job)
+	case job.Range == nil && job.From == nil:
+		err = s.runCatchUpJob(jobCtx, job)
+	default:
+		err = s.runSlotJob(jobCtx, job)
+	}
+
+	if ctx.Err() != nil {
+		// Shutting down; the job stays running and is requeued on the next start
+		return
+	}
+	if jobCtx.Err() != nil {
+		log.Infof("Backfill job %s stopped", job.ID.Hex())
+		return
+	}
+
+	job.Status = enums.BackfillJobCompleted
+	if err != nil {
+		job.Status = enums.BackfillJobFailed
+		job.LastError = err.Error()
+	}
+	finished, finishErr := s.jobRepo.Finish(ctx, job)
+	if finishErr != nil {
+		log.Errorf("Failed to finish backfill job %s: %v", job.ID.Hex(), finishErr)
+		return
+	}
+	if !finished {
+		// Paused or cancelled just as the work ran out; that status stands
+		return
+	}
+
+	if err != nil {
+		log.Errorf("Backfill job %s failed: %v", job.ID.Hex(), err)
+	} else {
+		log.Infof("Backfill job %s completed", job.ID.Hex())
+	}
+	s.record(*job)
+}
+
+// watch stops the job once its stored status is no longer running.
+func (s *Service) watch(ctx context.Context, id primitive.ObjectID, stop context.CancelFunc) {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job, err := s.jobRepo.Get(ctx, id)
+			if err != nil {
+				log.Warnf("Failed to check status of backfill job %s: %v", id.Hex(), err)
+				continue
+			}
+			if job.Status != enums.BackfillJobRunning {
+				stop()
+				return
+			}
+		}
+	}
+}
+
+// runSlotJob processes the parts of the job's range not completed yet, saving progress after
+// every chunk.
+func (s *Service) runSlotJob(ctx context.Context, job *entity.BackfillJob) error {
+	if job.Range == nil {
+		window, err := s.backfillService.ResolveTimeWindow(ctx, *job.From, *job.To)
+		if err != nil {
+			return err
+		}
+		if window == nil {
+			log.Infof("Backfill job %s covers no blocks", job.ID.Hex())
+			return nil
+		}
+		job.Range = window
+	}
+	job.Progress = entity.BackfillProgress{Done: countSlots(job.Completed), Total: job.Range.End - job.Range.Start + 1}
+	if err := s.jobRepo.SaveProgress(ctx, job); err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	missing := backfillTransaction.MissingSlotRanges(job.Range.Start, job.Range.End, job.Completed)
+	failed := s.backfillService.ProcessSlotRanges(ctx, missing, func(chunk entity.SlotRange) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		job.Completed = mergeSlotRange(job.Completed, chunk)
+		job.Progress.Done = countSlots(job.Completed)
+		// Progress outlives a pause, so it is saved even once the job was stopped
+		if err := s.jobRepo.SaveProgress(context.Background(), job); err != nil {
+			return err
+		}
+		s.record(*job)
+		return nil
+	})
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if failed > 0 {
+		job.Errors += failed
+		return fmt.Errorf("failed to backfill %d chunks between slots %d and %d", failed, job.Range.Start, job.Range.End)
+	}
+	return nil
+}
+
+// runCatchUpJob processes the slots produced since the backfill watermark. The range ledger
+// records the chunks processed, so a resumed job skips them.
+func (s *Service) runCatchUpJob(ctx context.Context, job *entity.BackfillJob) error {
+	return s.backfillService.BackfillMissedSlots(ctx, func(done, total uint64) {
+		job.Progress = entity.BackfillProgress{Done: done, Total: total}
+		// Progress outlives a pause, so it is saved even once the job was stopped
+		if err := s.jobRepo.SaveProgress(context.Background(), job); err != nil {
+			log.Warnf("Failed to save progress of backfill job %s: %v", job.ID.Hex(), err)
+		}
+		s.record(*job)
+	})
+}
+
+// runWalletJob sweeps the monitored addresses. Their cursors make a resumed job skip history it
+// already processed.
+func (s *Service) runWalletJob(ctx context.Context, job *entity.BackfillJob) error {
+	previousErrors := job.Errors
+	return s.backfillService.BackfillWallets(ctx, func(done, failed, total int) {
+		if ctx.Err() != nil {
+			// The address was interrupted rather than failed; its cursor resumes it
+			return
+		}
+		job.Progress = entity.BackfillProgress{Done: uint64(done), Total: uint64(total)}
+		job.Errors = previousErrors + int64(failed)
+		if err := s.jobRepo.SaveProgress(ctx, job); err != nil {
+			log.Warnf("Failed to save progress of backfill job %s: %v", job.ID.Hex(), err)
+		}
+		s.record(*job)
+	})
+}
+
+func (s *Service) record(job entity.BackfillJob) {
+	s.monitor.Record(entity.NewEvent(entity.BackfillJobEvent, job))
+}
+
+// mergeSlotRange adds the range to the ranges ordered by start, merging it with the ranges it
+// overlaps or touches.
+func mergeSlotRange(ranges []entity.SlotRange, added entity.SlotRange) []entity.SlotRange {
+	merged := make([]entity.SlotRange, 0, len(ranges)+1)
+	for _, r := range ranges {
+		switch {
+		case r.End+1 < added.Start:
+			merged = append(merged, r)
+		case added.End+1 < r.Start:
+			merged = append(merged, added)
+			added = r
+		default:
+			if r.Start < added.Start {
+				added.Start = r.Start
+			}
+			if r.End > added.End {
+				added.End = r.End
+			}
+		}
+	}
+	return append(merged, added)
+}
+
+func countSlots(ranges []entity.SlotRange) uint64 {
+	var count uint64
+	for _, r := range ranges {
+		count += r.End - r.Start + 1
+	}
+	return count
+}
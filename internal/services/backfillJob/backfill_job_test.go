@@ -0,0 +1,134 @@
+package backfillJob
+
+import (
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+	"reflect"
+	"testing"
+)
+
+func TestMergeSlotRange(t *testing.T) {
+	tests := []struct {
+		name   string
+		ranges []entity.SlotRange
+		added  entity.SlotRange
+		want   []entity.SlotRange
+	}{
+		{
+			name:  "first range",
+			added: entity.SlotRange{Start: 10, End: 19},
+			want:  []entity.SlotRange{{Start: 10, End: 19}},
+		},
+		{
+			name:   "after the others",
+			ranges: []entity.SlotRange{{Start: 10, End: 19}},
+			added:  entity.SlotRange{Start: 30, End: 39},
+			want:   []entity.SlotRange{{Start: 10, End: 19}, {Start: 30, End: 39}},
+		},
+		{
+			name:   "before the others",
+			ranges: []entity.SlotRange{{Start: 30, End: 39}},
+			added:  entity.SlotRange{Start: 10, End: 19},
+			want:   []entity.SlotRange{{Start: 10, End: 19}, {Start: 30, End: 39}},
+		},
+		{
+			name:   "between two ranges",
+			ranges: []entity.SlotRange{{Start: 0, End: 9}, {Start: 40, End: 49}},
+			added:  entity.SlotRange{Start: 20, End: 29},
+			want:   []entity.SlotRange{{Start: 0, End: 9}, {Start: 20, End: 29}, {Start: 40, End: 49}},
+		},
+		{
+			name:   "adjacent on both sides",
+			ranges: []entity.SlotRange{{Start: 0, End: 9}, {Start: 20, End: 29}},
+			added:  entity.SlotRange{Start: 10, End: 19},
+			want:   []entity.SlotRange{{Start: 0, End: 29}},
+		},
+		{
+			name:   "overlapping the end",
+			ranges: []entity.SlotRange{{Start: 10, End: 19}},
+			added:  entity.SlotRange{Start: 15, End: 25},
+			want:   []entity.SlotRange{{Start: 10, End: 25}},
+		},
+		{
+			name:   "overlapping the start",
+			ranges: []entity.SlotRange{{Start: 10, End: 19}},
+			added:  entity.SlotRange{Start: 5, End: 12},
+			want:   []entity.SlotRange{{Start: 5, End: 19}},
+		},
+		{
+			name:   "inside a range",
+			ranges: []entity.SlotRange{{Start: 10, End: 19}},
+			added:  entity.SlotRange{Start: 12, End: 15},
+			want:   []entity.SlotRange{{Start: 10, End: 19}},
+		},
+		{
+			name:   "covering several ranges",
+			ranges: []entity.SlotRange{{Start: 10, End: 19}, {Start: 30, End: 39}, {Start: 60, End: 69}},
+			added:  entity.SlotRange{Start: 5, End: 45},
+			want:   []entity.SlotRange{{Start: 5, End: 45}, {Start: 60, End: 69}},
+		},
+		{
+			name:   "single slot gap is kept",
+			ranges: []entity.SlotRange{{Start: 0, End: 9}},
+			added:  entity.SlotRange{Start: 11, End: 19},
+			want:   []entity.SlotRange{{Start: 0, End: 9}, {Start: 11, End: 19}},
+		},
+		{
+			name:   "starting at slot zero",
+			ranges: []entity.SlotRange{{Start: 1, End: 9}},
+			added:  entity.SlotRange{Start: 0, End: 0},
+			want:   []entity.SlotRange{{Start: 0, End: 9}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeSlotRange(tt.ranges, tt.added); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeSlotRange(%v, %v) = %v, want %v", tt.ranges, tt.added, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMergeSlotRangeOutOfOrder merges chunks in the order concurrent workers may finish them.
+func TestMergeSlotRangeOutOfOrder(t *testing.T) {
+	chunks := []entity.SlotRange{
+		{Start: 300, End: 399},
+		{Start: 0, End: 99},
+		{Start: 200, End: 299},
+		{Start: 500, End: 599},
+		{Start: 100, End: 199},
+	}
+
+	var ranges []entity.SlotRange
+	for _, chunk := range chunks {
+		ranges = mergeSlotRange(ranges, chunk)
+	}
+
+	want := []entity.SlotRange{{Start: 0, End: 399}, {Start: 500, End: 599}}
+	if !reflect.DeepEqual(ranges, want) {
+		t.Errorf("merged ranges = %v, want %v", ranges, want)
+	}
+	if got := countSlots(ranges); got != 500 {
+		t.Errorf("countSlots = %d, want 500", got)
+	}
+}
+
+func TestCountSlots(t *testing.T) {
+	tests := []struct {
+		name   string
+		ranges []entity.SlotRange
+		want   uint64
+	}{
+		{name: "no ranges", want: 0},
+		{name: "single slot", ranges: []entity.SlotRange{{Start: 7, End: 7}}, want: 1},
+		{name: "several ranges", ranges: []entity.SlotRange{{Start: 0, End: 9}, {Start: 20, End: 24}}, want: 15},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countSlots(tt.ranges); got != tt.want {
+				t.Errorf("countSlots(%v) = %d, want %d", tt.ranges, got, tt.want)
+			}
+		})
+	}
+}
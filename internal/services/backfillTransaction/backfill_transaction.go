@@ -6,115 +6,246 @@ import (
 	"github.com/avast/retry-go"
 	"github.com/delaram-gholampoor-sagha/SOLSniffer/configs"
 	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/contracts/repositories"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
 	log "github.com/delaram-gholampoor-sagha/SOLSniffer/internal/logger"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
 	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/tokenTransactionProcessor"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/watchlist"
 	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/transport/solanaClient"
 	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/utils"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Service struct {
 	solanaClient            *solanaClient.SolanaClient
 	metadataRepo            repositories.BackfillTransactionRepository
+	cursorRepo              repositories.AddressCursor
 	tokenTransactionService *tokenTransactionProcessor.Service
 	backfillConfig          *configs.BackfillConfig
+	watchlist               *watchlist.Service
+	tokens                  []string
 }
 
-func New(solanaClient *solanaClient.SolanaClient, config *configs.BackfillConfig, metadataRepo repositories.BackfillTransactionRepository, transactionService *tokenTransactionProcessor.Service) *Service {
+func New(solanaClient *solanaClient.SolanaClient, config *configs.BackfillConfig, metadataRepo repositories.BackfillTransactionRepository, cursorRepo repositories.AddressCursor, transactionService *tokenTransactionProcessor.Service, watchlist *watchlist.Service, tokens []string) *Service {
 	return &Service{
 		solanaClient:            solanaClient,
 		metadataRepo:            metadataRepo,
+		cursorRepo:              cursorRepo,
 		tokenTransactionService: transactionService,
 		backfillConfig:          config,
+		watchlist:               watchlist,
+		tokens:                  tokens,
 	}
 }
 
-func (s *Service) BackfillMissedBlocks(ctx context.Context) error {
-	// Get the latest block height and the last processed block height
-	currentBlock, err := s.solanaClient.GetBlockHeight(ctx)
+// BackfillMissedSlots processes every block produced since the last processed slot. Chunks are
+// processed concurrently and recorded in the range ledger as they complete; the watermark only
+// advances once every earlier slot is done, so an interrupted run resumes precisely from the
+// ledger. progress is called with the slots done and the slots to process after every chunk.
+func (s *Service) BackfillMissedSlots(ctx context.Context, progress func(done, total uint64)) error {
+	// Backfill draws from its own RPC budget so it never throttles live processing.
+	ctx = solanaClient.WithTraffic(ctx, enums.RPCTrafficBackfill)
+
+	// Get the latest slot and the last processed slot
+	currentSlot, err := s.solanaClient.GetSlot(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch current slot: %w", err)
+	}
+
+	lastProcessedSlot, err := s.getLastProcessedSlot(ctx)
 	if err != nil {
 		return err
 	}
 
-	lastProcessedBlock, err := s.getLastProcessedBlock(ctx)
+	// Without a watermark there is nothing to catch up on; start tracking from the current slot
+	if lastProcessedSlot == 0 {
+		log.Infof("No processed slot recorded; starting backfill watermark at slot %d", currentSlot)
+		return s.metadataRepo.UpdateLastProcessedSlot(ctx, currentSlot)
+	}
+
+	completed, err := s.metadataRepo.GetCompletedSlotRanges(ctx)
 	if err != nil {
 		return err
 	}
 
-	// Semaphore to control the concurrency
+	// Iterate through the slots not covered by the ledger yet
+	missing := MissingSlotRanges(lastProcessedSlot+1, currentSlot, completed)
+	var mu sync.Mutex
+	var done, total uint64
+	for _, r := range missing {
+		total += r.End - r.Start + 1
+	}
+	progress(done, total)
+
+	failed := s.ProcessSlotRanges(ctx, missing, func(chunk entity.SlotRange) error {
+		// The chunk was processed, so it is recorded even once ctx was cancelled
+		watermark, err := s.metadataRepo.CompleteSlotRange(context.Background(), chunk)
+		if err != nil {
+			return fmt.Errorf("failed to record backfilled slots %d to %d: %w", chunk.Start, chunk.End, err)
+		}
+		log.Infof("Backfilled slots %d to %d; processed up to slot %d", chunk.Start, chunk.End, watermark)
+
+		mu.Lock()
+		defer mu.Unlock()
+		done += chunk.End - chunk.Start + 1
+		progress(done, total)
+		return nil
+	})
+
+	if failed > 0 {
+		return fmt.Errorf("failed to backfill %d chunks up to slot %d", failed, currentSlot)
+	}
+	return nil
+}
+
+// ProcessSlotRanges splits the ranges into chunks of ChunkSize slots and processes them
+// concurrently, calling done for each chunk that was fully processed. It returns the number of
+// chunks that failed. Once ctx is done no further chunks are started.
+func (s *Service) ProcessSlotRanges(ctx context.Context, ranges []entity.SlotRange, done func(chunk entity.SlotRange) error) int64 {
+	// Backfill draws from its own RPC budget so it never throttles live processing.
+	ctx = solanaClient.WithTraffic(ctx, enums.RPCTrafficBackfill)
+
+	// Semaphores to control the concurrency of chunks and of the blocks within them
+	chunkSem := make(chan struct{}, s.backfillConfig.MaxConcurrency)
 	sem := make(chan struct{}, s.backfillConfig.MaxConcurrency)
 	var wg sync.WaitGroup
+	var failed atomic.Int64
 
-	// Iterate through the blocks to backfill
-	for start := lastProcessedBlock + 1; start <= currentBlock; start += s.backfillConfig.ChunkSize {
-		end := s.calculateEndBlock(start, currentBlock)
+	for _, gap := range ranges {
+		for start := gap.Start; start <= gap.End && ctx.Err() == nil; start += uint64(s.backfillConfig.ChunkSize) {
+			end := s.calculateEndSlot(start, gap.End)
 
-		wg.Add(1)
-		go func(start, end int64) {
-			defer wg.Done()
-			s.processBlockRange(ctx, start, end, sem)
-		}(start, end)
+			chunkSem <- struct{}{}
+			wg.Add(1)
+			go func(chunk entity.SlotRange) {
+				defer wg.Done()
+				defer func() { <-chunkSem }()
+
+				if err := s.processSlotRange(ctx, chunk.Start, chunk.End, sem); err != nil {
+					log.Errorf("Failed to backfill slots %d to %d: %v", chunk.Start, chunk.End, err)
+					failed.Add(1)
+					return
+				}
+				if err := done(chunk); err != nil {
+					log.Errorf("%v", err)
+					failed.Add(1)
+				}
+			}(entity.SlotRange{Start: start, End: end})
+		}
 	}
 
 	// Wait for all goroutines to finish
 	wg.Wait()
-	return nil
+	return failed.Load()
+}
+
+// MissingSlotRanges returns the parts of [from, to] not covered by the completed ranges, which
+// are ordered by start.
+func MissingSlotRanges(from, to uint64, completed []entity.SlotRange) []entity.SlotRange {
+	var missing []entity.SlotRange
+	next := from
+	for _, done := range completed {
+		if next > to {
+			break
+		}
+		if done.End < next {
+			continue
+		}
+		if done.Start > next {
+			missing = append(missing, entity.SlotRange{Start: next, End: minSlot(done.Start-1, to)})
+		}
+		next = done.End + 1
+	}
+	if next <= to {
+		missing = append(missing, entity.SlotRange{Start: next, End: to})
+	}
+	return missing
+}
+
+func minSlot(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
 }
 
-// getLastProcessedBlock retrieves the last processed block from the metadata repository
-func (s *Service) getLastProcessedBlock(ctx context.Context) (int64, error) {
-	lastProcessedBlock, err := s.metadataRepo.GetLastProcessedBlock(ctx)
+// getLastProcessedSlot retrieves the last processed slot from the metadata repository
+func (s *Service) getLastProcessedSlot(ctx context.Context) (uint64, error) {
+	lastProcessedSlot, err := s.metadataRepo.GetLastProcessedSlot(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("failed to fetch last processed block: %w", err)
+		return 0, fmt.Errorf("failed to fetch last processed slot: %w", err)
 	}
-	return lastProcessedBlock, nil
+	return lastProcessedSlot, nil
 }
 
-// calculateEndBlock calculates the end block based on the chunk size and the current block
-func (s *Service) calculateEndBlock(start, currentBlock int64) int64 {
-	end := start + s.backfillConfig.ChunkSize - 1
-	if end > currentBlock {
-		end = currentBlock
+// calculateEndSlot calculates the end slot based on the chunk size and the last slot to backfill
+func (s *Service) calculateEndSlot(start, lastSlot uint64) uint64 {
+	end := start + uint64(s.backfillConfig.ChunkSize) - 1
+	if end > lastSlot {
+		end = lastSlot
 	}
 	return end
 }
 
-// processBlockRange processes a range of blocks with retry logic
-func (s *Service) processBlockRange(ctx context.Context, start, end int64, sem chan struct{}) {
-	for block := start; block <= end; block++ {
+// processSlotRange processes the blocks produced between start and end with retry logic. Slots
+// without a block were skipped by their leader and count as done.
+func (s *Service) processSlotRange(ctx context.Context, start, end uint64, sem chan struct{}) error {
+	slots, err := s.solanaClient.GetBlocks(ctx, start, end)
+	if err != nil {
+		return fmt.Errorf("failed to list blocks between slots %d and %d: %w", start, end, err)
+	}
+
+	var wg sync.WaitGroup
+	var failed atomic.Int64
+
+	for _, slot := range slots {
 		sem <- struct{}{} // Acquire a semaphore slot
-		go func(block int64) {
+		wg.Add(1)
+		go func(slot uint64) {
+			defer wg.Done()
 			defer func() { <-sem }() // Release the semaphore slot
 
-			// Retry logic for processing the block
+			// Retry logic for processing the slot
 			err := retry.Do(
 				func() error {
-					return s.processBlock(ctx, block)
+					return s.processSlot(ctx, slot)
 				},
 				retry.Attempts(3),
 				retry.Delay(2*time.Second),
 				retry.DelayType(retry.BackOffDelay),
+				retry.Context(ctx),
 				retry.OnRetry(func(n uint, err error) {
-					log.Warnf("Retrying block %d (attempt %d): %v", block, n+1, err)
+					log.Warnf("Retrying slot %d (attempt %d): %v", slot, n+1, err)
 				}),
 			)
 
 			if err != nil {
-				log.Errorf("Failed to process block %d after retries: %v", block, err)
+				log.Errorf("Failed to process slot %d after retries: %v", slot, err)
+				failed.Add(1)
 			}
-		}(block)
+		}(slot)
 	}
-}
 
-func (s *Service) processBlock(ctx context.Context, block int64) error {
-	// Convert block to uint64 for compatibility with the Solana client
-	uint64Block := uint64(block)
+	// Wait for all goroutines to finish
+	wg.Wait()
+
+	if count := failed.Load(); count > 0 {
+		return fmt.Errorf("failed to process %d blocks between slots %d and %d", count, start, end)
+	}
+	return nil
+}
 
+func (s *Service) processSlot(ctx context.Context, slot uint64) error {
 	// Fetch block details using the client
-	blockDetails, err := s.solanaClient.GetBlock(ctx, uint64Block)
+	blockDetails, err := s.solanaClient.GetBlock(ctx, slot)
 	if err != nil {
-		return fmt.Errorf("failed to fetch block %d: %w", block, err)
+		if solanaClient.IsSkippedSlot(err) {
+			log.Debugf("Slot %d was skipped; nothing to process", slot)
+			return nil
+		}
+		return fmt.Errorf("failed to fetch block at slot %d: %w", slot, err)
 	}
 
 	// Convert block time to int64 (Unix timestamp)
@@ -124,27 +255,33 @@ func (s *Service) processBlock(ctx context.Context, block int64) error {
 		blockTimeUnix = &timestamp
 	}
 
-	// Process each transaction in the block
-	for _, tx := range blockDetails.Transactions {
+	// Process each transaction in the block. A transaction that fails fails the slot, which is
+	// then processed again; transactions already stored are replaced.
+	var failed int
+	for i, tx := range blockDetails.Transactions {
 		clientTx := utils.ConvertToClientTransaction(
-			&tx.Transaction,         // types.Transaction
-			tx.Meta,                 // TransactionMeta (client.TransactionMeta)
-			tx.AccountKeys,          // AccountKeys
-			blockDetails.ParentSlot, // Slot
-			blockTimeUnix,           // BlockTime as int64
+			&tx.Transaction, // types.Transaction
+			tx.Meta,         // TransactionMeta (client.TransactionMeta)
+			tx.AccountKeys,  // AccountKeys
+			slot,            // Slot
+			blockTimeUnix,   // BlockTime as int64
 		)
 
 		// Process the transaction using the transaction processor
-		if err := s.tokenTransactionService.ProcessTransaction(ctx, clientTx); err != nil {
-			log.Errorf("Failed to process transaction in block %d: %v", block, err)
+		transactionIndex := i
+		txContext := tokenTransactionProcessor.TransactionContext{
+			Commitment:       s.solanaClient.Commitment(),
+			TransactionIndex: &transactionIndex,
+		}
+		if err := s.tokenTransactionService.ProcessTransaction(ctx, clientTx, txContext); err != nil {
+			log.Errorf("Failed to process transaction in slot %d: %v", slot, err)
+			failed++
 		}
 	}
-
-	// Update the last processed block
-	if err := s.metadataRepo.UpdateLastProcessedBlock(ctx, block); err != nil {
-		return fmt.Errorf("failed to update last processed block to %d: %w", block, err)
+	if failed > 0 {
+		return fmt.Errorf("failed to process %d transactions in slot %d", failed, slot)
 	}
 
-	log.Infof("Successfully processed block %d", block)
+	log.Infof("Successfully processed slot %d", slot)
 	return nil
 }
@@ -0,0 +1,185 @@
+package backfillTransaction
+
+import (
+	"context"
+	"fmt"
+	"github.com/avast/retry-go"
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
+	log "github.com/delaram-gholampoor-sagha/SOLSniffer/internal/logger"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/tokenTransactionProcessor"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/transport/solanaClient"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// signaturePageSize is the most signatures getSignaturesForAddress returns per call.
+const signaturePageSize = 1000
+
+// BackfillWallets pages the signature history of every monitored wallet and of their token
+// accounts for the monitored tokens, and processes the transactions it has not seen yet. Each
+// address keeps a cursor in Mongo, so a sweep resumes where it stopped and the next one ends at
+// the newest signature of the last completed sweep. If progress is set, it is called after each
+// address with the number of addresses done and failed so far out of the total.
+func (s *Service) BackfillWallets(ctx context.Context, progress func(done, failed, total int)) error {
+	// Backfill draws from its own RPC budget so it never throttles live processing.
+	ctx = solanaClient.WithTraffic(ctx, enums.RPCTrafficBackfill)
+
+	addresses, err := s.walletAddresses(ctx)
+	if err != nil {
+		return err
+	}
+
+	// A transfer between two monitored addresses shows up in both histories; process it once.
+	seen := &sync.Map{}
+	var failed int
+	for i, address := range addresses {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := s.backfillAddress(ctx, address, seen); err != nil {
+			log.Errorf("Failed to backfill history of %s: %v", address, err)
+			failed++
+		}
+		if progress != nil {
+			progress(i+1-failed, failed, len(addresses))
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to backfill history of %d of %d addresses", failed, len(addresses))
+	}
+	return nil
+}
+
+// walletAddresses returns the monitored wallets followed by their token accounts for the
+// monitored tokens. Token transfers only reference the token accounts, not the wallet itself.
+func (s *Service) walletAddresses(ctx context.Context) ([]string, error) {
+	var addresses []string
+	for _, wallet := range s.watchlist.Wallets() {
+		addresses = append(addresses, wallet.Address)
+		for _, mint := range s.tokens {
+			accounts, err := s.solanaClient.GetTokenAccountsByOwner(ctx, wallet.Address, mint)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list token accounts of %s for mint %s: %w", wallet.Address, mint, err)
+			}
+			addresses = append(addresses, accounts...)
+		}
+	}
+	return addresses, nil
+}
+
+// backfillAddress sweeps the history of one address from its newest signature back to the
+// newest signature of the previous sweep, saving the cursor after every page.
+func (s *Service) backfillAddress(ctx context.Context, address string, seen *sync.Map) error {
+	cursor, err := s.cursorRepo.Get(ctx, address)
+	if err != nil {
+		return err
+	}
+
+	for {
+		page, err := s.solanaClient.GetSignaturesForAddress(ctx, address, client.GetSignaturesForAddressConfig{
+			Limit:  signaturePageSize,
+			Before: cursor.Before,
+			Until:  cursor.Newest,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list signatures before %q: %w", cursor.Before, err)
+		}
+
+		// The first page of a sweep holds the signature the next sweep has to stop at
+		if cursor.SweepNewest == "" && len(page) > 0 {
+			cursor.SweepNewest = page[0].Signature
+		}
+
+		var signatures []string
+		for _, signature := range page {
+			if _, loaded := seen.LoadOrStore(signature.Signature, struct{}{}); !loaded {
+				signatures = append(signatures, signature.Signature)
+			}
+		}
+		if err := s.processSignatures(ctx, signatures); err != nil {
+			return err
+		}
+
+		if len(page) < signaturePageSize {
+			// Reached the end of the history or the previous sweep
+			if cursor.SweepNewest != "" {
+				cursor.Newest = cursor.SweepNewest
+			}
+			cursor.SweepNewest = ""
+			cursor.Before = ""
+			if err := s.cursorRepo.Save(ctx, cursor); err != nil {
+				return err
+			}
+			log.Infof("Backfilled history of %s up to signature %s", address, cursor.Newest)
+			return nil
+		}
+
+		cursor.Before = page[len(page)-1].Signature
+		if err := s.cursorRepo.Save(ctx, cursor); err != nil {
+			return err
+		}
+	}
+}
+
+// processSignatures fetches and processes the transactions concurrently with retry logic.
+func (s *Service) processSignatures(ctx context.Context, signatures []string) error {
+	sem := make(chan struct{}, s.backfillConfig.MaxConcurrency)
+	var wg sync.WaitGroup
+	var failed atomic.Int64
+
+	for _, signature := range signatures {
+		sem <- struct{}{} // Acquire a semaphore slot
+		wg.Add(1)
+		go func(signature string) {
+			defer wg.Done()
+			defer func() { <-sem }() // Release the semaphore slot
+
+			err := retry.Do(
+				func() error {
+					return s.processSignature(ctx, signature)
+				},
+				retry.Attempts(3),
+				retry.Delay(2*time.Second),
+				retry.DelayType(retry.BackOffDelay),
+				retry.Context(ctx),
+				retry.OnRetry(func(n uint, err error) {
+					log.Warnf("Retrying transaction %s (attempt %d): %v", signature, n+1, err)
+				}),
+			)
+
+			if err != nil {
+				log.Errorf("Failed to process transaction %s after retries: %v", signature, err)
+				failed.Add(1)
+			}
+		}(signature)
+	}
+
+	// Wait for all goroutines to finish
+	wg.Wait()
+
+	if count := failed.Load(); count > 0 {
+		return fmt.Errorf("failed to process %d of %d transactions", count, len(signatures))
+	}
+	return nil
+}
+
+func (s *Service) processSignature(ctx context.Context, signature string) error {
+	tx, err := s.solanaClient.GetTransaction(ctx, signature)
+	if err != nil {
+		return fmt.Errorf("failed to fetch transaction %s: %w", signature, err)
+	}
+	if tx == nil {
+		return fmt.Errorf("transaction %s not found", signature)
+	}
+
+	txContext := tokenTransactionProcessor.TransactionContext{
+		Commitment: s.solanaClient.Commitment(),
+	}
+	if err := s.tokenTransactionService.ProcessTransaction(ctx, tx, txContext); err != nil {
+		return fmt.Errorf("failed to process transaction %s: %w", signature, err)
+	}
+	return nil
+}
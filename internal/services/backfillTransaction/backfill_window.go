@@ -0,0 +1,84 @@
+package backfillTransaction
+
+import (
+	"context"
+	"fmt"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/transport/solanaClient"
+	"time"
+)
+
+// blockTimeline is the part of the Solana client a time window is resolved with.
+type blockTimeline interface {
+	GetFirstAvailableBlock(ctx context.Context) (uint64, error)
+	GetSlot(ctx context.Context) (uint64, error)
+	GetBlocksWithLimit(ctx context.Context, start, limit uint64) ([]uint64, error)
+	GetBlockTime(ctx context.Context, slot uint64) (time.Time, error)
+}
+
+// ResolveTimeWindow returns the slots holding the blocks produced in [from, to), or nil if there
+// are none the node still has. Time window backfill jobs are processed as the slot range it
+// returns.
+func (s *Service) ResolveTimeWindow(ctx context.Context, from, to time.Time) (*entity.SlotRange, error) {
+	return resolveTimeWindow(solanaClient.WithTraffic(ctx, enums.RPCTrafficBackfill), s.solanaClient, from, to)
+}
+
+func resolveTimeWindow(ctx context.Context, timeline blockTimeline, from, to time.Time) (*entity.SlotRange, error) {
+	firstSlot, err := timeline.GetFirstAvailableBlock(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch first available block: %w", err)
+	}
+	currentSlot, err := timeline.GetSlot(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch current slot: %w", err)
+	}
+
+	start, err := firstSlotAtOrAfter(ctx, timeline, from, firstSlot, currentSlot)
+	if err != nil {
+		return nil, err
+	}
+	end, err := firstSlotAtOrAfter(ctx, timeline, to, start, currentSlot)
+	if err != nil {
+		return nil, err
+	}
+	if end <= start {
+		return nil, nil
+	}
+	return &entity.SlotRange{Start: start, End: end - 1}, nil
+}
+
+// firstSlotAtOrAfter binary searches [low, high] for the first slot whose block was produced at
+// or after t. Block times only ever increase with the slot, so skipped slots are resolved to the
+// next block, and the skipped slots right before the block found may be returned in its place
+// since they hold nothing. It returns high+1, or the skipped slots before it, if every block in
+// the range is older than t.
+func firstSlotAtOrAfter(ctx context.Context, timeline blockTimeline, t time.Time, low, high uint64) (uint64, error) {
+	// The search runs over the half-open [low, end), where end stands for "no such slot"
+	end := high + 1
+	for low < end {
+		mid := low + (end-low)/2
+
+		blocks, err := timeline.GetBlocksWithLimit(ctx, mid, 1)
+		if err != nil {
+			return 0, fmt.Errorf("failed to find block at or after slot %d: %w", mid, err)
+		}
+		if len(blocks) == 0 || blocks[0] > high {
+			// No block between mid and the end of the range
+			end = mid
+			continue
+		}
+
+		block := blocks[0]
+		blockTime, err := timeline.GetBlockTime(ctx, block)
+		if err != nil {
+			return 0, fmt.Errorf("failed to fetch block time of slot %d: %w", block, err)
+		}
+		if blockTime.Before(t) {
+			low = block + 1
+		} else {
+			end = mid
+		}
+	}
+	return low, nil
+}
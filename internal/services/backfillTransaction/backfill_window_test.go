@@ -0,0 +1,158 @@
+package backfillTransaction
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+var windowEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// fakeTimeline is a chain whose blocks were produced one second per slot after windowEpoch.
+// Slots missing from blocks were skipped. Blocks past tip exist but are not confirmed yet, as
+// getSlot may lag getBlocksWithLimit.
+type fakeTimeline struct {
+	first  uint64
+	tip    uint64
+	blocks []uint64
+	err    error
+}
+
+func slotTime(slot uint64) time.Time {
+	return windowEpoch.Add(time.Duration(slot) * time.Second)
+}
+
+func (f *fakeTimeline) GetFirstAvailableBlock(context.Context) (uint64, error) {
+	return f.first, nil
+}
+
+func (f *fakeTimeline) GetSlot(context.Context) (uint64, error) {
+	return f.tip, nil
+}
+
+func (f *fakeTimeline) GetBlocksWithLimit(_ context.Context, start, limit uint64) ([]uint64, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	i := sort.Search(len(f.blocks), func(i int) bool { return f.blocks[i] >= start })
+	var blocks []uint64
+	for ; i < len(f.blocks) && uint64(len(blocks)) < limit; i++ {
+		blocks = append(blocks, f.blocks[i])
+	}
+	return blocks, nil
+}
+
+func (f *fakeTimeline) GetBlockTime(_ context.Context, slot uint64) (time.Time, error) {
+	return slotTime(slot), nil
+}
+
+// blocksIn returns the blocks of the timeline within the range.
+func (f *fakeTimeline) blocksIn(start, end uint64) []uint64 {
+	var blocks []uint64
+	for _, block := range f.blocks {
+		if block >= start && block <= end {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+func newFakeTimeline() *fakeTimeline {
+	// Slots 100 to 122 with 105 to 107 and 110 skipped; 121 and 122 are past the tip
+	timeline := &fakeTimeline{first: 100, tip: 120}
+	for slot := uint64(100); slot <= 122; slot++ {
+		if (slot >= 105 && slot <= 107) || slot == 110 {
+			continue
+		}
+		timeline.blocks = append(timeline.blocks, slot)
+	}
+	return timeline
+}
+
+func TestResolveTimeWindow(t *testing.T) {
+	tests := []struct {
+		name string
+		from time.Time
+		to   time.Time
+		want []uint64
+	}{
+		{name: "exact block times", from: slotTime(102), to: slotTime(104), want: []uint64{102, 103}},
+		{name: "starting in skipped slots", from: slotTime(105), to: slotTime(110), want: []uint64{108, 109}},
+		{name: "ending in skipped slots", from: slotTime(103), to: slotTime(106), want: []uint64{103, 104}},
+		{name: "between blocks", from: slotTime(102).Add(time.Millisecond), to: slotTime(103)},
+		{name: "only skipped slots", from: slotTime(105), to: slotTime(108)},
+		{name: "empty window", from: slotTime(103), to: slotTime(103)},
+		{name: "before the first available block", from: slotTime(50), to: slotTime(102), want: []uint64{100, 101}},
+		{name: "spanning the tip", from: slotTime(118), to: slotTime(200), want: []uint64{118, 119, 120}},
+		{name: "past the tip", from: slotTime(121), to: slotTime(200)},
+		{name: "in the future", from: slotTime(500), to: slotTime(600)},
+		{name: "whole history", from: slotTime(0), to: slotTime(1000), want: []uint64{100, 101, 102, 103, 104, 108, 109, 111, 112, 113, 114, 115, 116, 117, 118, 119, 120}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeline := newFakeTimeline()
+			window, err := resolveTimeWindow(context.Background(), timeline, tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("resolveTimeWindow failed: %v", err)
+			}
+
+			if tt.want == nil {
+				if window != nil {
+					t.Errorf("window = %+v holding blocks %v, want none", *window, timeline.blocksIn(window.Start, window.End))
+				}
+				return
+			}
+			if window == nil {
+				t.Fatalf("window = nil, want blocks %v", tt.want)
+			}
+			if window.Start < timeline.first || window.End > timeline.tip {
+				t.Errorf("window %+v exceeds the available slots %d to %d", *window, timeline.first, timeline.tip)
+			}
+			if got := timeline.blocksIn(window.Start, window.End); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("window %+v holds blocks %v, want %v", *window, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirstSlotAtOrAfter(t *testing.T) {
+	tests := []struct {
+		name      string
+		t         time.Time
+		low, high uint64
+		want      uint64
+	}{
+		{name: "block at the time", t: slotTime(103), low: 100, high: 120, want: 103},
+		{name: "first block of the range", t: slotTime(0), low: 100, high: 120, want: 100},
+		{name: "last block of the range", t: slotTime(120), low: 100, high: 120, want: 120},
+		{name: "every block older", t: slotTime(121), low: 100, high: 120, want: 121},
+		{name: "skipped slots at the end of the range", t: slotTime(104).Add(time.Millisecond), low: 100, high: 107, want: 105},
+		{name: "skipped slots before the block", t: slotTime(108), low: 100, high: 120, want: 105},
+		{name: "single slot range", t: slotTime(0), low: 104, high: 104, want: 104},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := firstSlotAtOrAfter(context.Background(), newFakeTimeline(), tt.t, tt.low, tt.high)
+			if err != nil {
+				t.Fatalf("firstSlotAtOrAfter failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("firstSlotAtOrAfter = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveTimeWindowError(t *testing.T) {
+	timeline := newFakeTimeline()
+	timeline.err = errors.New("node unavailable")
+
+	if _, err := resolveTimeWindow(context.Background(), timeline, slotTime(100), slotTime(110)); !errors.Is(err, timeline.err) {
+		t.Errorf("resolveTimeWindow error = %v, want %v", err, timeline.err)
+	}
+}
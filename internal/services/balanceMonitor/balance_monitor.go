@@ -0,0 +1,210 @@
+package balanceMonitor
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/token"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/contracts/repositories"
+	log "github.com/delaram-gholampoor-sagha/SOLSniffer/internal/logger"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/amount"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/request"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/instructionDecoder"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/watchlist"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/transport/solanaClient"
+	"sync"
+	"time"
+)
+
+// Service keeps the balances of the watched wallets and their token accounts. Each account update
+// is decoded into the wallet's lamports or the token account's amount, and persisted as a balance
+// change when the amount differs from the last balance recorded.
+type Service struct {
+	solanaClient *solanaClient.SolanaClient
+	balanceRepo  repositories.Balance
+	watchlist    *watchlist.Service
+
+	mu sync.Mutex
+	// decimals caches the decimals of each mint
+	decimals map[string]uint8
+	// latest caches the last balance recorded for each account
+	latest map[string]*entity.Balance
+}
+
+func New(solanaClient *solanaClient.SolanaClient, balanceRepo repositories.Balance, watchlist *watchlist.Service) *Service {
+	return &Service{
+		solanaClient: solanaClient,
+		balanceRepo:  balanceRepo,
+		watchlist:    watchlist,
+		decimals:     make(map[string]uint8),
+		latest:       make(map[string]*entity.Balance),
+	}
+}
+
+// ProcessAccountUpdate records the balance carried by an accountSubscribe notification for the
+// address.
+func (s *Service) ProcessAccountUpdate(ctx context.Context, address string, message []byte) error {
+	update, err := request.ParseAccountUpdate(message)
+	if err != nil {
+		return err
+	}
+
+	value := update.Params.Result.Value
+	var data []byte
+	if len(value.Data) > 0 {
+		data, err = base64.StdEncoding.DecodeString(value.Data[0])
+		if err != nil {
+			return fmt.Errorf("failed to decode account data of %s: %w", address, err)
+		}
+	}
+	return s.observe(ctx, address, update.Params.Result.Context.Slot, value.Lamports, common.PublicKeyFromString(value.Owner), data)
+}
+
+// Refresh reads the balance of the address over RPC. Account notifications only report changes,
+// so this records the balance an account already has when it is first watched, and catches up
+// on changes made while the stream was down.
+func (s *Service) Refresh(ctx context.Context, address string) error {
+	account, err := s.solanaClient.GetAccountInfoAndContext(ctx, address)
+	if err != nil {
+		return fmt.Errorf("failed to fetch account %s: %w", address, err)
+	}
+	return s.observe(ctx, address, account.Context.Slot, account.Value.Lamports, account.Value.Owner, account.Value.Data)
+}
+
+// Forget drops the cached balance of an address that is no longer watched.
+func (s *Service) Forget(address string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.latest, address)
+}
+
+func (s *Service) observe(ctx context.Context, address string, slot, lamports uint64, owner common.PublicKey, data []byte) error {
+	balance, err := s.decode(ctx, address, lamports, owner, data)
+	if err != nil || balance == nil {
+		return err
+	}
+	balance.Slot = slot
+
+	previous, err := s.previous(ctx, address)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	// The cache may have moved on while the previous balance was loaded
+	if latest, ok := s.latest[address]; ok {
+		previous = latest
+	}
+	// Accounts without lamports do not exist, such as associated token accounts never created
+	if previous == nil && lamports == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	if previous != nil && (slot <= previous.Slot || previous.Amount.Cmp(balance.Amount) == 0) {
+		s.mu.Unlock()
+		return nil
+	}
+	s.latest[address] = balance
+	s.mu.Unlock()
+
+	change := &entity.BalanceChange{
+		Account:   balance.Account,
+		Wallet:    balance.Wallet,
+		Mint:      balance.Mint,
+		Slot:      balance.Slot,
+		Amount:    balance.Amount,
+		RawAmount: balance.RawAmount,
+		Decimals:  balance.Decimals,
+		Timestamp: balance.UpdatedAt,
+	}
+	if previous != nil {
+		delta := balance.Amount.Sub(previous.Amount)
+		change.Change = &delta
+	}
+
+	if err := s.balanceRepo.Save(ctx, balance, change); err != nil {
+		// Reload from the repository next time, so the change is not lost
+		s.Forget(address)
+		return err
+	}
+
+	log.Infof("Balance of %s for %s is %s at slot %d", balance.Wallet, balance.Mint, balance.Amount, balance.Slot)
+	return nil
+}
+
+// decode reads the balance of a watched wallet or token account, or returns nil for addresses
+// that are no longer watched. A closed token account holds nothing.
+func (s *Service) decode(ctx context.Context, address string, lamports uint64, owner common.PublicKey, data []byte) (*entity.Balance, error) {
+	balance := &entity.Balance{Account: address, UpdatedAt: time.Now()}
+
+	if _, ok := s.watchlist.Direction(address); ok {
+		balance.Wallet = address
+		balance.Mint = instructionDecoder.NativeSOLMint
+		balance.Amount = amount.New(lamports, instructionDecoder.NativeSOLDecimals)
+	} else if wallet, ok := s.watchlist.OwnerOf(address); ok {
+		mint, _ := s.watchlist.MintOf(address)
+		raw := uint64(0)
+		if instructionDecoder.IsTokenProgram(owner) && instructionDecoder.IsTokenAccountData(data) {
+			tokenAccount, err := token.TokenAccountFromData(data[:token.TokenAccountSize])
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode token account %s: %w", address, err)
+			}
+			raw = tokenAccount.Amount
+		}
+
+		decimals, err := s.mintDecimals(ctx, mint)
+		if err != nil {
+			return nil, err
+		}
+		balance.Wallet = wallet
+		balance.Mint = mint
+		balance.Amount = amount.New(raw, decimals)
+	} else {
+		return nil, nil
+	}
+
+	balance.RawAmount = balance.Amount.RawString()
+	balance.Decimals = balance.Amount.Decimals()
+	return balance, nil
+}
+
+// previous returns the last balance recorded for the address, loading it from the repository
+// the first time.
+func (s *Service) previous(ctx context.Context, address string) (*entity.Balance, error) {
+	s.mu.Lock()
+	latest, ok := s.latest[address]
+	s.mu.Unlock()
+	if ok {
+		return latest, nil
+	}
+	return s.balanceRepo.Get(ctx, address)
+}
+
+func (s *Service) mintDecimals(ctx context.Context, mint string) (uint8, error) {
+	s.mu.Lock()
+	decimals, ok := s.decimals[mint]
+	s.mu.Unlock()
+	if ok {
+		return decimals, nil
+	}
+
+	account, err := s.solanaClient.GetAccountInfo(ctx, mint)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch mint %s: %w", mint, err)
+	}
+	// Token-2022 mints with extensions are longer than the base layout
+	if !instructionDecoder.IsTokenProgram(account.Owner) || len(account.Data) < token.MintAccountSize {
+		return 0, fmt.Errorf("account %s is not a mint", mint)
+	}
+	mintAccount, err := token.MintAccountFromData(account.Data[:token.MintAccountSize])
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode mint %s: %w", mint, err)
+	}
+
+	s.mu.Lock()
+	s.decimals[mint] = mintAccount.Decimals
+	s.mu.Unlock()
+	return mintAccount.Decimals, nil
+}
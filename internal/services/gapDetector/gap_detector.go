@@ -0,0 +1,169 @@
+package gapDetector
+
+import (
+	"context"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/contracts/repositories"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
+	log "github.com/delaram-gholampoor-sagha/SOLSniffer/internal/logger"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/backfillJob"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/transport/solanaClient"
+	"sync"
+	"time"
+)
+
+const (
+	// persistInterval is how often the last stream slot is written to Mongo. A restart may
+	// backfill the few slots seen since the last write again.
+	persistInterval = 5 * time.Second
+	// scheduleInterval is how often pending gaps are checked against the node's slot.
+	scheduleInterval = 5 * time.Second
+)
+
+// Service watches the slot stream for slots it never saw, whether the stream dropped, the
+// process was down, or notifications were lost, and schedules a backfill job for each gap.
+type Service struct {
+	solanaClient *solanaClient.SolanaClient
+	metadataRepo repositories.BackfillTransactionRepository
+	jobs         *backfillJob.Service
+
+	mu          sync.Mutex
+	lastSlot    uint64
+	persistedAt time.Time
+	gaps        []entity.SlotRange
+}
+
+func New(solanaClient *solanaClient.SolanaClient, metadataRepo repositories.BackfillTransactionRepository, jobs *backfillJob.Service) *Service {
+	return &Service{
+		solanaClient: solanaClient,
+		metadataRepo: metadataRepo,
+		jobs:         jobs,
+	}
+}
+
+// Start resumes from the last slot the stream saw before a restart, so the downtime is detected
+// as a gap, and schedules gaps until ctx is done.
+func (s *Service) Start(ctx context.Context) error {
+	lastSlot, err := s.metadataRepo.GetLastStreamSlot(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if lastSlot > s.lastSlot {
+		s.lastSlot = lastSlot
+	}
+	s.mu.Unlock()
+
+	go s.scheduleGaps(ctx)
+	return nil
+}
+
+// ObserveSlot records a slot from the stream. Slots between the last one seen and the new slot's
+// parent were produced but never streamed; skipped slots do not count, as the parent already
+// skips them.
+func (s *Service) ObserveSlot(ctx context.Context, slot, parent uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lastSlot != 0 && parent > s.lastSlot {
+		gap := entity.SlotRange{Start: s.lastSlot + 1, End: parent}
+		log.Warnf("Live stream missed slots %d to %d", gap.Start, gap.End)
+		s.gaps = append(s.gaps, gap)
+	}
+
+	// Slots of abandoned forks can arrive after newer ones
+	if slot <= s.lastSlot {
+		return
+	}
+	s.lastSlot = slot
+
+	if time.Since(s.persistedAt) >= persistInterval {
+		s.persistedAt = time.Now()
+		// Stay before gaps not scheduled yet, so a restart detects them again
+		if len(s.gaps) > 0 {
+			slot = s.gaps[0].Start - 1
+		}
+		go func() {
+			if err := s.metadataRepo.UpdateLastStreamSlot(ctx, slot); err != nil {
+				log.Warnf("Failed to record last stream slot %d: %v", slot, err)
+			}
+		}()
+	}
+}
+
+// scheduleGaps creates a backfill job for each gap once the node's commitment has reached its
+// end. getBlocks only lists blocks at that commitment, so earlier the newest blocks of the gap
+// would look skipped.
+func (s *Service) scheduleGaps(ctx context.Context) {
+	ticker := time.NewTicker(scheduleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			pending := len(s.gaps)
+			s.mu.Unlock()
+			if pending == 0 {
+				continue
+			}
+
+			currentSlot, err := s.solanaClient.GetSlot(ctx)
+			if err != nil {
+				log.Warnf("Failed to fetch current slot for gap backfill: %v", err)
+				continue
+			}
+			s.schedule(ctx, currentSlot)
+		}
+	}
+}
+
+func (s *Service) schedule(ctx context.Context, currentSlot uint64) {
+	s.mu.Lock()
+	gaps := s.gaps
+	s.gaps = nil
+	s.mu.Unlock()
+
+	var remaining []entity.SlotRange
+	for _, gap := range gaps {
+		if gap.End > currentSlot {
+			remaining = append(remaining, gap)
+			continue
+		}
+
+		gap := gap
+		job := &entity.BackfillJob{Mode: enums.BackfillSlots, Range: &gap}
+		if err := s.jobs.Create(ctx, job); err != nil {
+			log.Errorf("Failed to schedule backfill of slots %d to %d: %v", gap.Start, gap.End, err)
+			remaining = append(remaining, gap)
+			continue
+		}
+		log.Infof("Scheduled backfill job %s for missed slots %d to %d", job.ID.Hex(), gap.Start, gap.End)
+	}
+
+	s.mu.Lock()
+	s.gaps = append(remaining, s.gaps...)
+	s.mu.Unlock()
+}
+
+// StreamResumed accounts for a reconnect of the stream. Once a slot was seen, the first slot
+// update after the reconnect reveals the outage through its parent; before that, the outage is
+// only known by time, so it is backfilled as a time window.
+func (s *Service) StreamResumed(ctx context.Context, disconnectedAt, reconnectedAt time.Time) {
+	s.mu.Lock()
+	seen := s.lastSlot != 0
+	s.mu.Unlock()
+	if seen {
+		return
+	}
+
+	job := &entity.BackfillJob{Mode: enums.BackfillSlots, From: &disconnectedAt, To: &reconnectedAt}
+	if err := s.jobs.Create(ctx, job); err != nil {
+		log.Errorf("Failed to schedule backfill of stream outage from %s to %s: %v", disconnectedAt, reconnectedAt, err)
+		return
+	}
+	log.Infof("Scheduled backfill job %s for stream outage from %s to %s", job.ID.Hex(), disconnectedAt, reconnectedAt)
+}
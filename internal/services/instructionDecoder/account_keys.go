@@ -0,0 +1,38 @@
+package instructionDecoder
+
+import (
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+)
+
+// AccountKeys returns the full account key list that compiled instructions, balances
+// and token balances index into: the static keys of the message, followed by the writable and
+// then the readonly addresses a v0 transaction loaded from address lookup tables.
+//
+// Transactions fetched through the client already carry this list in AccountKeys; it is rebuilt
+// from the meta when a caller assembled the transaction without it.
+func AccountKeys(tx *client.Transaction) []common.PublicKey {
+	static := tx.Transaction.Message.Accounts
+	if tx.Meta == nil {
+		if len(tx.AccountKeys) > 0 {
+			return tx.AccountKeys
+		}
+		return static
+	}
+
+	loaded := tx.Meta.LoadedAddresses
+	total := len(static) + len(loaded.Writable) + len(loaded.Readonly)
+	if len(tx.AccountKeys) == total {
+		return tx.AccountKeys
+	}
+
+	keys := make([]common.PublicKey, 0, total)
+	keys = append(keys, static...)
+	for _, address := range loaded.Writable {
+		keys = append(keys, common.PublicKeyFromString(address))
+	}
+	for _, address := range loaded.Readonly {
+		keys = append(keys, common.PublicKeyFromString(address))
+	}
+	return keys
+}
@@ -0,0 +1,131 @@
+package instructionDecoder
+
+import (
+	"fmt"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// anchorDecoder decodes the instructions and "Program data:" events of one Anchor program from
+// its IDL.
+type anchorDecoder struct {
+	idl   *anchorIDL
+	types map[string]anchorTypeDef
+}
+
+func newAnchorDecoder(idl *anchorIDL) *anchorDecoder {
+	types := make(map[string]anchorTypeDef, len(idl.Types))
+	for _, typeDef := range idl.Types {
+		types[typeDef.Name] = typeDef
+	}
+	return &anchorDecoder{idl: idl, types: types}
+}
+
+// RegisterAnchorIDLs loads every *.json Anchor IDL in the directory and registers an instruction
+// and event log decoder for the program each one describes.
+func (r *Registry) RegisterAnchorIDLs(directory string) error {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return fmt.Errorf("failed to read IDL directory %s: %w", directory, err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".json") {
+			continue
+		}
+
+		idl, err := loadAnchorIDL(filepath.Join(directory, entry.Name()))
+		if err != nil {
+			return err
+		}
+		programID := common.PublicKeyFromString(idl.Address)
+		if programID == (common.PublicKey{}) {
+			return fmt.Errorf("IDL %s has an invalid program address %s", entry.Name(), idl.Address)
+		}
+
+		decoder := newAnchorDecoder(idl)
+		r.Register(programID, decoder)
+		r.RegisterLogDecoder(programID, decoder)
+	}
+	return nil
+}
+
+func (d *anchorDecoder) Decode(instruction Instruction) ([]Event, error) {
+	for _, definition := range d.idl.Instructions {
+		if !discriminatorMatches(definition.Discriminator, instruction.Data) {
+			continue
+		}
+
+		reader := &borshReader{data: instruction.Data[len(definition.Discriminator):], types: d.types}
+		args, err := reader.decodeFields(definition.Args)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", d.idl.Name, definition.Name, err)
+		}
+
+		return []Event{ProgramEvent{
+			Origin:   instruction.Origin(),
+			Program:  d.idl.Name,
+			Name:     definition.Name,
+			Source:   enums.ProgramEventInstruction,
+			Accounts: nameAccounts(definition.Accounts, instruction.Accounts),
+			Data:     args,
+		}}, nil
+	}
+	return nil, nil
+}
+
+func (d *anchorDecoder) DecodeLog(entry ProgramLog) ([]Event, error) {
+	for _, definition := range d.idl.Events {
+		if !discriminatorMatches(definition.Discriminator, entry.Data) {
+			continue
+		}
+
+		reader := &borshReader{data: entry.Data[len(definition.Discriminator):], types: d.types}
+		fields, err := reader.decodeFields(definition.Fields)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", d.idl.Name, definition.Name, err)
+		}
+
+		return []Event{ProgramEvent{
+			Origin:  Origin{ProgramID: entry.ProgramID.ToBase58(), Position: entry.Position},
+			Program: d.idl.Name,
+			Name:    definition.Name,
+			Source:  enums.ProgramEventLog,
+			Data:    fields,
+		}}, nil
+	}
+	return nil, nil
+}
+
+// nameAccounts pairs the IDL account names with the instruction's accounts. Accounts inside a
+// group are named "group_account"; accounts beyond the IDL list are "remaining_<n>". The names
+// become Mongo field names, which must not contain dots.
+func nameAccounts(items []anchorAccountItem, addresses []string) map[string]string {
+	named := make(map[string]string, len(addresses))
+	position := 0
+
+	var walk func(items []anchorAccountItem, prefix string)
+	walk = func(items []anchorAccountItem, prefix string) {
+		for _, item := range items {
+			if len(item.Accounts) > 0 {
+				walk(item.Accounts, prefix+item.Name+"_")
+				continue
+			}
+			if position >= len(addresses) {
+				return
+			}
+			named[prefix+item.Name] = addresses[position]
+			position++
+		}
+	}
+	walk(items, "")
+
+	for i := position; i < len(addresses); i++ {
+		named["remaining_"+strconv.Itoa(i-position)] = addresses[i]
+	}
+	return named
+}
@@ -0,0 +1,246 @@
+package instructionDecoder
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// anchorIDL is the part of an Anchor IDL needed for decoding. Both the legacy format (Anchor
+// before 0.30, program address under metadata, discriminators derived from names) and the current
+// format (top-level address, explicit discriminators) are accepted.
+type anchorIDL struct {
+	Address  string `json:"address"`
+	Name     string `json:"name"`
+	Metadata struct {
+		Name    string `json:"name"`
+		Address string `json:"address"`
+	} `json:"metadata"`
+	Instructions []anchorInstruction `json:"instructions"`
+	Events       []anchorEvent       `json:"events"`
+	Types        []anchorTypeDef     `json:"types"`
+}
+
+type anchorInstruction struct {
+	Name          string              `json:"name"`
+	Discriminator []int               `json:"discriminator"`
+	Accounts      []anchorAccountItem `json:"accounts"`
+	Args          []anchorField       `json:"args"`
+}
+
+// anchorAccountItem is either an account or a named group of accounts.
+type anchorAccountItem struct {
+	Name     string              `json:"name"`
+	Accounts []anchorAccountItem `json:"accounts"`
+}
+
+type anchorEvent struct {
+	Name          string        `json:"name"`
+	Discriminator []int         `json:"discriminator"`
+	Fields        []anchorField `json:"fields"`
+}
+
+type anchorField struct {
+	Name string     `json:"name"`
+	Type anchorType `json:"type"`
+}
+
+type anchorTypeDef struct {
+	Name string `json:"name"`
+	Type struct {
+		Kind     string          `json:"kind"`
+		Fields   json.RawMessage `json:"fields"`
+		Variants []struct {
+			Name   string          `json:"name"`
+			Fields json.RawMessage `json:"fields"`
+		} `json:"variants"`
+	} `json:"type"`
+}
+
+// anchorType is a Borsh type as written in an IDL: a primitive name such as "u64", or one of
+// vec, option, coption, array and defined.
+type anchorType struct {
+	Primitive string
+	Vec       *anchorType
+	Option    *anchorType
+	COption   *anchorType
+	Array     *anchorType
+	ArrayLen  int
+	Defined   string
+}
+
+func (t *anchorType) UnmarshalJSON(data []byte) error {
+	var primitive string
+	if err := json.Unmarshal(data, &primitive); err == nil {
+		t.Primitive = primitive
+		return nil
+	}
+
+	var composite struct {
+		Vec     *anchorType       `json:"vec"`
+		Option  *anchorType       `json:"option"`
+		COption *anchorType       `json:"coption"`
+		Array   []json.RawMessage `json:"array"`
+		Defined json.RawMessage   `json:"defined"`
+	}
+	if err := json.Unmarshal(data, &composite); err != nil {
+		return fmt.Errorf("invalid IDL type %s: %w", data, err)
+	}
+
+	t.Vec, t.Option, t.COption = composite.Vec, composite.Option, composite.COption
+	if len(composite.Array) == 2 {
+		t.Array = &anchorType{}
+		if err := json.Unmarshal(composite.Array[0], t.Array); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(composite.Array[1], &t.ArrayLen); err != nil {
+			return fmt.Errorf("unsupported IDL array length %s", composite.Array[1])
+		}
+	}
+	if len(composite.Defined) > 0 {
+		// Legacy IDLs name the type directly, current ones wrap it in {"name": ...}.
+		if err := json.Unmarshal(composite.Defined, &t.Defined); err != nil {
+			var named struct {
+				Name string `json:"name"`
+			}
+			if err := json.Unmarshal(composite.Defined, &named); err != nil {
+				return fmt.Errorf("invalid IDL defined type %s", composite.Defined)
+			}
+			t.Defined = named.Name
+		}
+	}
+
+	if t.Vec == nil && t.Option == nil && t.COption == nil && t.Array == nil && t.Defined == "" {
+		return fmt.Errorf("unsupported IDL type %s", data)
+	}
+	return nil
+}
+
+// parseAnchorFields reads the fields of a struct or enum variant, which are either named
+// ({"name", "type"} objects) or a tuple of bare types.
+func parseAnchorFields(raw json.RawMessage) ([]anchorField, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, err
+	}
+
+	fields := make([]anchorField, 0, len(items))
+	for i, item := range items {
+		var field anchorField
+		if isNamedAnchorField(item) {
+			if err := json.Unmarshal(item, &field); err != nil {
+				return nil, err
+			}
+		} else {
+			field.Name = fmt.Sprintf("%d", i)
+			if err := json.Unmarshal(item, &field.Type); err != nil {
+				return nil, err
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func isNamedAnchorField(item json.RawMessage) bool {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(item, &object); err != nil {
+		return false
+	}
+	_, hasName := object["name"]
+	_, hasType := object["type"]
+	return hasName && hasType
+}
+
+// loadAnchorIDL reads an IDL file and fills in what the legacy format leaves implicit: the
+// program address, the discriminators and the fields of events.
+func loadAnchorIDL(path string) (*anchorIDL, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var idl anchorIDL
+	if err := json.Unmarshal(data, &idl); err != nil {
+		return nil, fmt.Errorf("failed to parse IDL %s: %w", path, err)
+	}
+
+	if idl.Address == "" {
+		idl.Address = idl.Metadata.Address
+	}
+	if idl.Address == "" {
+		return nil, fmt.Errorf("IDL %s has no program address", path)
+	}
+	if idl.Name == "" {
+		idl.Name = idl.Metadata.Name
+	}
+
+	for i := range idl.Instructions {
+		instruction := &idl.Instructions[i]
+		if len(instruction.Discriminator) == 0 {
+			instruction.Discriminator = anchorDiscriminator("global:" + toSnakeCase(instruction.Name))
+		}
+	}
+
+	for i := range idl.Events {
+		event := &idl.Events[i]
+		if len(event.Discriminator) == 0 {
+			event.Discriminator = anchorDiscriminator("event:" + event.Name)
+		}
+		if len(event.Fields) == 0 {
+			for _, typeDef := range idl.Types {
+				if typeDef.Name == event.Name {
+					if event.Fields, err = parseAnchorFields(typeDef.Type.Fields); err != nil {
+						return nil, fmt.Errorf("invalid fields for event %s in %s: %w", event.Name, path, err)
+					}
+				}
+			}
+		}
+	}
+	return &idl, nil
+}
+
+// anchorDiscriminator is the first eight bytes of the SHA-256 of the preimage, which is how Anchor
+// tags instructions ("global:<snake_case_name>") and events ("event:<Name>").
+func anchorDiscriminator(preimage string) []int {
+	sum := sha256.Sum256([]byte(preimage))
+	discriminator := make([]int, 8)
+	for i := range discriminator {
+		discriminator[i] = int(sum[i])
+	}
+	return discriminator
+}
+
+func discriminatorMatches(discriminator []int, data []byte) bool {
+	if len(discriminator) == 0 || len(data) < len(discriminator) {
+		return false
+	}
+	for i, b := range discriminator {
+		if int(data[i]) != b {
+			return false
+		}
+	}
+	return true
+}
+
+func toSnakeCase(name string) string {
+	var builder strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				builder.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
@@ -0,0 +1,228 @@
+package instructionDecoder
+
+import (
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testProgramID = "Stake11111111111111111111111111111111111111"
+
+// legacyIDL is in the format of Anchor before 0.30: the address is under metadata, the
+// discriminators are derived from the names and event fields are inline.
+const legacyIDL = `{
+	"version": "0.1.0",
+	"name": "vault",
+	"metadata": {"address": "` + testProgramID + `"},
+	"instructions": [{
+		"name": "depositFunds",
+		"accounts": [
+			{"name": "user", "isMut": true, "isSigner": true},
+			{"name": "pool", "accounts": [
+				{"name": "state", "isMut": true, "isSigner": false},
+				{"name": "vault", "isMut": true, "isSigner": false}
+			]}
+		],
+		"args": [{"name": "amount", "type": "u64"}, {"name": "memo", "type": {"option": "string"}}]
+	}],
+	"events": [{
+		"name": "Deposited",
+		"fields": [{"name": "user", "type": "publicKey", "index": false}, {"name": "amount", "type": "u64", "index": false}]
+	}]
+}`
+
+// currentIDL is in the format of Anchor 0.30 and later: a top-level address, explicit
+// discriminators and event fields declared as types.
+const currentIDL = `{
+	"address": "` + testProgramID + `",
+	"metadata": {"name": "vault", "version": "0.1.0", "spec": "0.1.0"},
+	"instructions": [{
+		"name": "withdraw",
+		"discriminator": [183, 18, 70, 156, 148, 109, 161, 34],
+		"accounts": [{"name": "user", "writable": true, "signer": true}],
+		"args": [{"name": "side", "type": {"defined": {"name": "Side"}}}]
+	}],
+	"events": [{"name": "Withdrawn", "discriminator": [1, 2, 3, 4, 5, 6, 7, 8]}],
+	"types": [
+		{"name": "Side", "type": {"kind": "enum", "variants": [{"name": "Base"}, {"name": "Quote"}]}},
+		{"name": "Withdrawn", "type": {"kind": "struct", "fields": [{"name": "amount", "type": "u64"}]}}
+	]
+}`
+
+func writeIDL(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "vault.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write IDL: %v", err)
+	}
+	return path
+}
+
+func discriminatorBytes(discriminator []int) []byte {
+	data := make([]byte, len(discriminator))
+	for i, b := range discriminator {
+		data[i] = byte(b)
+	}
+	return data
+}
+
+func TestLoadLegacyAnchorIDL(t *testing.T) {
+	idl, err := loadAnchorIDL(writeIDL(t, legacyIDL))
+	if err != nil {
+		t.Fatalf("loadAnchorIDL failed: %v", err)
+	}
+
+	if idl.Address != testProgramID || idl.Name != "vault" {
+		t.Errorf("loaded address %q and name %q", idl.Address, idl.Name)
+	}
+	if got, want := idl.Instructions[0].Discriminator, anchorDiscriminator("global:deposit_funds"); !reflect.DeepEqual(got, want) {
+		t.Errorf("instruction discriminator = %v, want %v", got, want)
+	}
+	if got, want := idl.Events[0].Discriminator, anchorDiscriminator("event:Deposited"); !reflect.DeepEqual(got, want) {
+		t.Errorf("event discriminator = %v, want %v", got, want)
+	}
+}
+
+func TestLoadCurrentAnchorIDL(t *testing.T) {
+	idl, err := loadAnchorIDL(writeIDL(t, currentIDL))
+	if err != nil {
+		t.Fatalf("loadAnchorIDL failed: %v", err)
+	}
+
+	if idl.Address != testProgramID || idl.Name != "vault" {
+		t.Errorf("loaded address %q and name %q", idl.Address, idl.Name)
+	}
+	if got := idl.Instructions[0].Discriminator; !reflect.DeepEqual(got, []int{183, 18, 70, 156, 148, 109, 161, 34}) {
+		t.Errorf("instruction discriminator = %v", got)
+	}
+	// Event fields are taken from the type of the same name
+	if len(idl.Events[0].Fields) != 1 || idl.Events[0].Fields[0].Name != "amount" {
+		t.Errorf("event fields = %+v", idl.Events[0].Fields)
+	}
+}
+
+func TestLoadAnchorIDLWithoutAddress(t *testing.T) {
+	if _, err := loadAnchorIDL(writeIDL(t, `{"name": "vault", "instructions": []}`)); err == nil {
+		t.Errorf("loadAnchorIDL succeeded without a program address")
+	}
+}
+
+func TestAnchorDecodeInstruction(t *testing.T) {
+	idl, err := loadAnchorIDL(writeIDL(t, legacyIDL))
+	if err != nil {
+		t.Fatalf("loadAnchorIDL failed: %v", err)
+	}
+	decoder := newAnchorDecoder(idl)
+
+	data := discriminatorBytes(idl.Instructions[0].Discriminator)
+	data = append(data, new(borshWriter).u64(5000).u8(1).str("rent").Bytes()...)
+	instruction := Instruction{
+		ProgramID: common.PublicKeyFromString(testProgramID),
+		Accounts:  []string{"user", "state", "vault", "extra"},
+		Data:      data,
+		Position:  Position{Index: 2},
+	}
+
+	events, err := decoder.Decode(instruction)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("Decode returned %d events, want 1", len(events))
+	}
+
+	event := events[0].(ProgramEvent)
+	if event.Program != "vault" || event.Name != "depositFunds" || event.Source != enums.ProgramEventInstruction {
+		t.Errorf("decoded %s.%s from %s", event.Program, event.Name, event.Source)
+	}
+	if event.Position.Index != 2 || event.ProgramID != testProgramID {
+		t.Errorf("decoded origin %+v", event.Origin)
+	}
+	wantAccounts := map[string]string{
+		"user":        "user",
+		"pool_state":  "state",
+		"pool_vault":  "vault",
+		"remaining_0": "extra",
+	}
+	if !reflect.DeepEqual(event.Accounts, wantAccounts) {
+		t.Errorf("accounts = %v, want %v", event.Accounts, wantAccounts)
+	}
+	wantData := map[string]any{"amount": "5000", "memo": "rent"}
+	if !reflect.DeepEqual(event.Data, wantData) {
+		t.Errorf("data = %#v, want %#v", event.Data, wantData)
+	}
+}
+
+func TestAnchorDecodeUnknownInstruction(t *testing.T) {
+	idl, err := loadAnchorIDL(writeIDL(t, legacyIDL))
+	if err != nil {
+		t.Fatalf("loadAnchorIDL failed: %v", err)
+	}
+
+	events, err := newAnchorDecoder(idl).Decode(Instruction{Data: []byte{0, 1, 2, 3, 4, 5, 6, 7, 8}})
+	if err != nil || len(events) != 0 {
+		t.Errorf("Decode of an unknown instruction = %v, %v", events, err)
+	}
+}
+
+func TestAnchorDecodeTruncatedInstruction(t *testing.T) {
+	idl, err := loadAnchorIDL(writeIDL(t, legacyIDL))
+	if err != nil {
+		t.Fatalf("loadAnchorIDL failed: %v", err)
+	}
+
+	data := append(discriminatorBytes(idl.Instructions[0].Discriminator), 1, 2)
+	if _, err := newAnchorDecoder(idl).Decode(Instruction{Data: data}); err == nil {
+		t.Errorf("Decode of truncated arguments succeeded")
+	}
+}
+
+func TestAnchorDecodeLog(t *testing.T) {
+	idl, err := loadAnchorIDL(writeIDL(t, currentIDL))
+	if err != nil {
+		t.Fatalf("loadAnchorIDL failed: %v", err)
+	}
+
+	data := append(discriminatorBytes(idl.Events[0].Discriminator), new(borshWriter).u64(42).Bytes()...)
+	events, err := newAnchorDecoder(idl).DecodeLog(ProgramLog{
+		ProgramID: common.PublicKeyFromString(testProgramID),
+		Position:  Position{Index: 1},
+		Data:      data,
+	})
+	if err != nil {
+		t.Fatalf("DecodeLog failed: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("DecodeLog returned %d events, want 1", len(events))
+	}
+
+	event := events[0].(ProgramEvent)
+	if event.Name != "Withdrawn" || event.Source != enums.ProgramEventLog || event.Position.Index != 1 {
+		t.Errorf("decoded %s from %s at %+v", event.Name, event.Source, event.Position)
+	}
+	if !reflect.DeepEqual(event.Data, map[string]any{"amount": "42"}) {
+		t.Errorf("data = %#v", event.Data)
+	}
+}
+
+func TestRegisterAnchorIDLs(t *testing.T) {
+	directory := t.TempDir()
+	if err := os.WriteFile(filepath.Join(directory, "vault.json"), []byte(currentIDL), 0o600); err != nil {
+		t.Fatalf("failed to write IDL: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(directory, "notes.txt"), []byte("not an IDL"), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	registry := NewRegistry()
+	if err := registry.RegisterAnchorIDLs(directory); err != nil {
+		t.Fatalf("RegisterAnchorIDLs failed: %v", err)
+	}
+	programID := common.PublicKeyFromString(testProgramID)
+	if len(registry.decoders[programID]) != 1 || len(registry.logDecoders[programID]) != 1 {
+		t.Errorf("registered %d instruction and %d log decoders", len(registry.decoders[programID]), len(registry.logDecoders[programID]))
+	}
+}
@@ -0,0 +1,244 @@
+package instructionDecoder
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/blocto/solana-go-sdk/common"
+	"math"
+	"math/big"
+)
+
+// borshReader decodes Borsh-serialized data against IDL types into values that store cleanly in
+// Mongo: integers up to 64 bits signed become int64, u64 and 128-bit integers become base-10
+// strings, public keys become base58 strings, structs become maps and enums become a map with a
+// "variant" key next to the variant's fields.
+type borshReader struct {
+	data   []byte
+	offset int
+	types  map[string]anchorTypeDef
+}
+
+func (r *borshReader) read(n int) ([]byte, error) {
+	if n < 0 || r.offset+n > len(r.data) {
+		return nil, fmt.Errorf("unexpected end of data at offset %d reading %d bytes", r.offset, n)
+	}
+	b := r.data[r.offset : r.offset+n]
+	r.offset += n
+	return b, nil
+}
+
+func (r *borshReader) readLength() (int, error) {
+	b, err := r.read(4)
+	if err != nil {
+		return 0, err
+	}
+	length := binary.LittleEndian.Uint32(b)
+	if int(length) > len(r.data)-r.offset {
+		return 0, fmt.Errorf("length %d exceeds remaining data", length)
+	}
+	return int(length), nil
+}
+
+func (r *borshReader) decodeFields(fields []anchorField) (map[string]any, error) {
+	values := make(map[string]any, len(fields))
+	for _, field := range fields {
+		value, err := r.decode(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		values[field.Name] = value
+	}
+	return values, nil
+}
+
+func (r *borshReader) decode(t anchorType) (any, error) {
+	switch {
+	case t.Vec != nil:
+		length, err := r.readLength()
+		if err != nil {
+			return nil, err
+		}
+		return r.decodeSequence(*t.Vec, length)
+	case t.Array != nil:
+		return r.decodeSequence(*t.Array, t.ArrayLen)
+	case t.Option != nil:
+		tag, err := r.read(1)
+		if err != nil {
+			return nil, err
+		}
+		if tag[0] == 0 {
+			return nil, nil
+		}
+		return r.decode(*t.Option)
+	case t.COption != nil:
+		// COption uses a four byte tag and always reserves room for the value.
+		tag, err := r.read(4)
+		if err != nil {
+			return nil, err
+		}
+		value, err := r.decode(*t.COption)
+		if err != nil || binary.LittleEndian.Uint32(tag) == 0 {
+			return nil, err
+		}
+		return value, nil
+	case t.Defined != "":
+		return r.decodeDefined(t.Defined)
+	default:
+		return r.decodePrimitive(t.Primitive)
+	}
+}
+
+func (r *borshReader) decodeSequence(element anchorType, length int) (any, error) {
+	// Byte arrays are far more common than arrays of small numbers and read better as a slice of bytes.
+	if element.Primitive == "u8" {
+		return r.read(length)
+	}
+	values := make([]any, 0, length)
+	for i := 0; i < length; i++ {
+		value, err := r.decode(element)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func (r *borshReader) decodeDefined(name string) (any, error) {
+	typeDef, ok := r.types[name]
+	if !ok {
+		return nil, fmt.Errorf("type %s is not defined in the IDL", name)
+	}
+
+	switch typeDef.Type.Kind {
+	case "struct":
+		fields, err := parseAnchorFields(typeDef.Type.Fields)
+		if err != nil {
+			return nil, err
+		}
+		return r.decodeFields(fields)
+	case "enum":
+		tag, err := r.read(1)
+		if err != nil {
+			return nil, err
+		}
+		if int(tag[0]) >= len(typeDef.Type.Variants) {
+			return nil, fmt.Errorf("variant %d out of range for enum %s", tag[0], name)
+		}
+		variant := typeDef.Type.Variants[tag[0]]
+		fields, err := parseAnchorFields(variant.Fields)
+		if err != nil {
+			return nil, err
+		}
+		values, err := r.decodeFields(fields)
+		if err != nil {
+			return nil, err
+		}
+		values["variant"] = variant.Name
+		return values, nil
+	default:
+		return nil, fmt.Errorf("unsupported kind %q for type %s", typeDef.Type.Kind, name)
+	}
+}
+
+func (r *borshReader) decodePrimitive(primitive string) (any, error) {
+	switch primitive {
+	case "bool":
+		b, err := r.read(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case "u8", "i8":
+		b, err := r.read(1)
+		if err != nil {
+			return nil, err
+		}
+		if primitive == "i8" {
+			return int64(int8(b[0])), nil
+		}
+		return int64(b[0]), nil
+	case "u16", "i16":
+		b, err := r.read(2)
+		if err != nil {
+			return nil, err
+		}
+		value := binary.LittleEndian.Uint16(b)
+		if primitive == "i16" {
+			return int64(int16(value)), nil
+		}
+		return int64(value), nil
+	case "u32", "i32":
+		b, err := r.read(4)
+		if err != nil {
+			return nil, err
+		}
+		value := binary.LittleEndian.Uint32(b)
+		if primitive == "i32" {
+			return int64(int32(value)), nil
+		}
+		return int64(value), nil
+	case "u64", "i64":
+		b, err := r.read(8)
+		if err != nil {
+			return nil, err
+		}
+		value := binary.LittleEndian.Uint64(b)
+		if primitive == "i64" {
+			return int64(value), nil
+		}
+		return new(big.Int).SetUint64(value).String(), nil
+	case "u128", "i128":
+		b, err := r.read(16)
+		if err != nil {
+			return nil, err
+		}
+		return decodeInt128(b, primitive == "i128").String(), nil
+	case "f32":
+		b, err := r.read(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))), nil
+	case "f64":
+		b, err := r.read(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+	case "string", "bytes":
+		length, err := r.readLength()
+		if err != nil {
+			return nil, err
+		}
+		b, err := r.read(length)
+		if err != nil {
+			return nil, err
+		}
+		if primitive == "string" {
+			return string(b), nil
+		}
+		return b, nil
+	case "publicKey", "pubkey":
+		b, err := r.read(32)
+		if err != nil {
+			return nil, err
+		}
+		return common.PublicKeyFromBytes(b).ToBase58(), nil
+	default:
+		return nil, fmt.Errorf("unsupported primitive type %q", primitive)
+	}
+}
+
+// decodeInt128 reads a little-endian 128-bit integer, two's complement when signed.
+func decodeInt128(b []byte, signed bool) *big.Int {
+	bigEndian := make([]byte, len(b))
+	for i := range b {
+		bigEndian[len(b)-1-i] = b[i]
+	}
+	value := new(big.Int).SetBytes(bigEndian)
+	if signed && b[len(b)-1]&0x80 != 0 {
+		value.Sub(value, new(big.Int).Lsh(big.NewInt(1), 128))
+	}
+	return value
+}
@@ -0,0 +1,230 @@
+package instructionDecoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/blocto/solana-go-sdk/common"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// borshWriter builds Borsh-serialized test data.
+type borshWriter struct {
+	bytes.Buffer
+}
+
+func (w *borshWriter) u8(v uint8) *borshWriter {
+	w.WriteByte(v)
+	return w
+}
+
+func (w *borshWriter) u16(v uint16) *borshWriter {
+	binary.Write(&w.Buffer, binary.LittleEndian, v)
+	return w
+}
+
+func (w *borshWriter) u32(v uint32) *borshWriter {
+	binary.Write(&w.Buffer, binary.LittleEndian, v)
+	return w
+}
+
+func (w *borshWriter) u64(v uint64) *borshWriter {
+	binary.Write(&w.Buffer, binary.LittleEndian, v)
+	return w
+}
+
+func (w *borshWriter) str(v string) *borshWriter {
+	w.u32(uint32(len(v)))
+	w.WriteString(v)
+	return w
+}
+
+func parseType(t *testing.T, raw string) anchorType {
+	t.Helper()
+	var parsed anchorType
+	if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
+		t.Fatalf("failed to parse type %s: %v", raw, err)
+	}
+	return parsed
+}
+
+func TestBorshPrimitives(t *testing.T) {
+	key := common.PublicKeyFromString("So11111111111111111111111111111111111111112")
+	maxU128 := bytes.Repeat([]byte{0xff}, 16)
+
+	tests := []struct {
+		typ  string
+		data []byte
+		want any
+	}{
+		{typ: `"bool"`, data: []byte{1}, want: true},
+		{typ: `"u8"`, data: []byte{200}, want: int64(200)},
+		{typ: `"i8"`, data: []byte{0xff}, want: int64(-1)},
+		{typ: `"u16"`, data: new(borshWriter).u16(65535).Bytes(), want: int64(65535)},
+		{typ: `"i16"`, data: new(borshWriter).u16(0x8000).Bytes(), want: int64(math.MinInt16)},
+		{typ: `"u32"`, data: new(borshWriter).u32(4000000000).Bytes(), want: int64(4000000000)},
+		{typ: `"i32"`, data: new(borshWriter).u32(0xfffffffe).Bytes(), want: int64(-2)},
+		{typ: `"u64"`, data: new(borshWriter).u64(math.MaxUint64).Bytes(), want: "18446744073709551615"},
+		{typ: `"i64"`, data: new(borshWriter).u64(math.MaxUint64).Bytes(), want: int64(-1)},
+		{typ: `"u128"`, data: maxU128, want: "340282366920938463463374607431768211455"},
+		{typ: `"i128"`, data: maxU128, want: "-1"},
+		{typ: `"f64"`, data: new(borshWriter).u64(math.Float64bits(1.5)).Bytes(), want: 1.5},
+		{typ: `"string"`, data: new(borshWriter).str("hello").Bytes(), want: "hello"},
+		{typ: `"bytes"`, data: new(borshWriter).str("ab").Bytes(), want: []byte("ab")},
+		{typ: `"publicKey"`, data: key.Bytes(), want: key.ToBase58()},
+		{typ: `"pubkey"`, data: key.Bytes(), want: key.ToBase58()},
+	}
+
+	for _, test := range tests {
+		reader := &borshReader{data: test.data}
+		got, err := reader.decode(parseType(t, test.typ))
+		if err != nil {
+			t.Errorf("decode %s failed: %v", test.typ, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("decode %s = %#v, want %#v", test.typ, got, test.want)
+		}
+		if reader.offset != len(test.data) {
+			t.Errorf("decode %s read %d of %d bytes", test.typ, reader.offset, len(test.data))
+		}
+	}
+}
+
+func TestBorshContainers(t *testing.T) {
+	tests := []struct {
+		name string
+		typ  string
+		data []byte
+		want any
+	}{
+		{
+			name: "vec",
+			typ:  `{"vec": "u16"}`,
+			data: new(borshWriter).u32(2).u16(1).u16(2).Bytes(),
+			want: []any{int64(1), int64(2)},
+		},
+		{
+			name: "byte array",
+			typ:  `{"array": ["u8", 3]}`,
+			data: []byte{1, 2, 3},
+			want: []byte{1, 2, 3},
+		},
+		{
+			name: "option none",
+			typ:  `{"option": "u64"}`,
+			data: []byte{0},
+			want: nil,
+		},
+		{
+			name: "option some",
+			typ:  `{"option": "u32"}`,
+			data: new(borshWriter).u8(1).u32(7).Bytes(),
+			want: int64(7),
+		},
+		{
+			name: "coption none reserves the value",
+			typ:  `{"coption": "u32"}`,
+			data: new(borshWriter).u32(0).u32(9).Bytes(),
+			want: nil,
+		},
+		{
+			name: "coption some",
+			typ:  `{"coption": "u32"}`,
+			data: new(borshWriter).u32(1).u32(9).Bytes(),
+			want: int64(9),
+		},
+	}
+
+	for _, test := range tests {
+		reader := &borshReader{data: test.data}
+		got, err := reader.decode(parseType(t, test.typ))
+		if err != nil {
+			t.Errorf("%s: decode failed: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: decode = %#v, want %#v", test.name, got, test.want)
+		}
+		if reader.offset != len(test.data) {
+			t.Errorf("%s: read %d of %d bytes", test.name, reader.offset, len(test.data))
+		}
+	}
+}
+
+func TestBorshDefinedTypes(t *testing.T) {
+	var types []anchorTypeDef
+	err := json.Unmarshal([]byte(`[
+		{"name": "Fee", "type": {"kind": "struct", "fields": [
+			{"name": "bps", "type": "u16"},
+			{"name": "recipient", "type": {"option": "string"}}
+		]}},
+		{"name": "Side", "type": {"kind": "enum", "variants": [
+			{"name": "Bid"},
+			{"name": "Ask", "fields": ["u8", {"defined": {"name": "Fee"}}]}
+		]}}
+	]`), &types)
+	if err != nil {
+		t.Fatalf("failed to parse types: %v", err)
+	}
+	typeMap := make(map[string]anchorTypeDef)
+	for _, typeDef := range types {
+		typeMap[typeDef.Name] = typeDef
+	}
+
+	data := new(borshWriter).u8(1).u8(4).u16(30).u8(1).str("treasury").Bytes()
+	reader := &borshReader{data: data, types: typeMap}
+	got, err := reader.decode(parseType(t, `{"defined": "Side"}`))
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+
+	want := map[string]any{
+		"variant": "Ask",
+		"0":       int64(4),
+		"1":       map[string]any{"bps": int64(30), "recipient": "treasury"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decode = %#v, want %#v", got, want)
+	}
+
+	reader = &borshReader{data: []byte{0}, types: typeMap}
+	got, err = reader.decode(parseType(t, `{"defined": "Side"}`))
+	if err != nil {
+		t.Fatalf("decode of unit variant failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, map[string]any{"variant": "Bid"}) {
+		t.Errorf("decode of unit variant = %#v", got)
+	}
+}
+
+func TestBorshErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		typ  string
+		data []byte
+	}{
+		{name: "truncated integer", typ: `"u64"`, data: []byte{1, 2, 3}},
+		{name: "length beyond data", typ: `"string"`, data: new(borshWriter).u32(10).str("").Bytes()},
+		{name: "vec beyond data", typ: `{"vec": "u64"}`, data: new(borshWriter).u32(2).u64(1).Bytes()},
+		{name: "enum variant out of range", typ: `{"defined": "Unit"}`, data: []byte{5}},
+		{name: "undefined type", typ: `{"defined": "Missing"}`, data: []byte{0}},
+		{name: "unsupported primitive", typ: `"u256"`, data: make([]byte, 32)},
+	}
+
+	types := map[string]anchorTypeDef{}
+	var unit anchorTypeDef
+	if err := json.Unmarshal([]byte(`{"name": "Unit", "type": {"kind": "enum", "variants": [{"name": "Only"}]}}`), &unit); err != nil {
+		t.Fatalf("failed to parse type: %v", err)
+	}
+	types["Unit"] = unit
+
+	for _, test := range tests {
+		reader := &borshReader{data: test.data, types: types}
+		if got, err := reader.decode(parseType(t, test.typ)); err == nil {
+			t.Errorf("%s: decode = %#v, want an error", test.name, got)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package instructionDecoder
+
+import "github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
+
+// Position locates an instruction within its transaction. InnerIndex is nil for top-level
+// instructions.
+type Position struct {
+	Index      int
+	InnerIndex *int
+}
+
+// Event is a domain event decoded from a single instruction.
+type Event interface {
+	Kind() enums.EventKind
+	GetOrigin() Origin
+}
+
+// Origin records which program and instruction an event was decoded from. Decoders copy it from
+// the instruction they decode.
+type Origin struct {
+	ProgramID string
+	Position  Position
+}
+
+func (o Origin) GetOrigin() Origin {
+	return o
+}
+
+// TransferEvent moves tokens, or lamports when Mint is NativeSOLMint, between two accounts.
+// Owners are left empty when the transaction does not reveal them.
+type TransferEvent struct {
+	Origin
+	Instruction      enums.TokenInstruction
+	Source           string
+	SourceOwner      string
+	Destination      string
+	DestinationOwner string
+	Mint             string
+	Amount           uint64
+	Decimals         uint8
+}
+
+func (TransferEvent) Kind() enums.EventKind { return enums.EventTransfer }
+
+// MintEvent creates new tokens in a token account.
+type MintEvent struct {
+	Origin
+	Instruction      enums.TokenInstruction
+	Mint             string
+	Destination      string
+	DestinationOwner string
+	Amount           uint64
+	Decimals         uint8
+}
+
+func (MintEvent) Kind() enums.EventKind { return enums.EventMint }
+
+// BurnEvent destroys tokens held by a token account.
+type BurnEvent struct {
+	Origin
+	Instruction enums.TokenInstruction
+	Mint        string
+	Source      string
+	SourceOwner string
+	Amount      uint64
+	Decimals    uint8
+}
+
+func (BurnEvent) Kind() enums.EventKind { return enums.EventBurn }
+
+// MemoEvent carries the text attached by the Memo program and the accounts that signed it.
+type MemoEvent struct {
+	Origin
+	Memo    string
+	Signers []string
+}
+
+func (MemoEvent) Kind() enums.EventKind { return enums.EventMemo }
+
+// StakeEvent is a change to a stake account signed by Authority. VoteAccount is set for
+// delegations, Recipient and Lamports for withdrawals.
+type StakeEvent struct {
+	Origin
+	Action       enums.StakeAction
+	StakeAccount string
+	Authority    string
+	VoteAccount  string
+	Recipient    string
+	Lamports     uint64
+}
+
+func (StakeEvent) Kind() enums.EventKind { return enums.EventStake }
+
+// SwapEvent exchanges one token for another through a protocol. No swap decoder is built in;
+// protocol decoders registered by callers emit it.
+type SwapEvent struct {
+	Origin
+	Trader       string
+	InputMint    string
+	InputAmount  uint64
+	OutputMint   string
+	OutputAmount uint64
+}
+
+func (SwapEvent) Kind() enums.EventKind { return enums.EventSwap }
+
+// ProgramEvent is a generic structured event decoded from a program's interface description,
+// either from an instruction or from an event the program logged. Accounts is only set for
+// instructions.
+type ProgramEvent struct {
+	Origin
+	Program  string
+	Name     string
+	Source   enums.ProgramEventSource
+	Accounts map[string]string
+	Data     map[string]any
+}
+
+func (ProgramEvent) Kind() enums.EventKind { return enums.EventProgram }
@@ -0,0 +1,23 @@
+package instructionDecoder
+
+import (
+	"github.com/blocto/solana-go-sdk/common"
+	"unicode/utf8"
+)
+
+// legacyMemoProgramID is the first version of the Memo program, still used by older wallets.
+var legacyMemoProgramID = common.PublicKeyFromString("Memo1UhkJRfHyvLMcVucJwxXeuD728EqVDDwQDxFMNo")
+
+// decodeMemoInstruction decodes a memo. The whole instruction data is the UTF-8 memo text and
+// every account passed to the instruction is a signer.
+func decodeMemoInstruction(instruction Instruction) ([]Event, error) {
+	if len(instruction.Data) == 0 || !utf8.Valid(instruction.Data) {
+		return nil, nil
+	}
+
+	return []Event{MemoEvent{
+		Origin:  instruction.Origin(),
+		Memo:    string(instruction.Data),
+		Signers: instruction.Accounts,
+	}}, nil
+}
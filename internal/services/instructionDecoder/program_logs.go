@@ -0,0 +1,62 @@
+package instructionDecoder
+
+import (
+	"encoding/base64"
+	"github.com/blocto/solana-go-sdk/common"
+	"strings"
+)
+
+const (
+	programDataPrefix = "Program data: "
+	logTruncated      = "Log truncated"
+)
+
+// programLogs extracts the "Program data:" entries from transaction logs. The runtime logs
+// "Program <id> invoke [depth]" when a program starts and "Program <id> success" or
+// "Program <id> failed: ..." when it returns, so a stack of invocations tells which program
+// emitted each entry and depth 1 invocations count the top-level instructions. Parsing stops at
+// a truncation marker because the stack can no longer be trusted after it.
+func programLogs(logMessages []string) []ProgramLog {
+	var entries []ProgramLog
+	var stack []common.PublicKey
+	topLevelIndex := -1
+
+	for _, line := range logMessages {
+		if strings.HasPrefix(line, logTruncated) {
+			break
+		}
+
+		if data, ok := strings.CutPrefix(line, programDataPrefix); ok {
+			if len(stack) == 0 {
+				continue
+			}
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+			if err != nil {
+				continue
+			}
+			entries = append(entries, ProgramLog{
+				ProgramID: stack[len(stack)-1],
+				Position:  Position{Index: topLevelIndex},
+				Data:      decoded,
+			})
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[0] != "Program" {
+			continue
+		}
+		switch {
+		case fields[2] == "invoke":
+			stack = append(stack, common.PublicKeyFromString(fields[1]))
+			if len(fields) > 3 && fields[3] == "[1]" {
+				topLevelIndex++
+			}
+		case fields[2] == "success" || fields[2] == "failed:":
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	return entries
+}
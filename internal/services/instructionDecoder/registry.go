@@ -0,0 +1,238 @@
+package instructionDecoder
+
+import (
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+	log "github.com/delaram-gholampoor-sagha/SOLSniffer/internal/logger"
+	"sync"
+)
+
+// TokenAccount holds what the transaction meta tells about a token account.
+type TokenAccount struct {
+	Mint     string
+	Owner    string
+	Decimals uint8
+}
+
+// Instruction is a compiled instruction with its program and accounts resolved to addresses.
+type Instruction struct {
+	ProgramID common.PublicKey
+	Accounts  []string
+	Data      []byte
+	Position  Position
+
+	// TokenAccounts indexes the pre and post token balances of the transaction by address.
+	TokenAccounts map[string]TokenAccount
+}
+
+func (i Instruction) Origin() Origin {
+	return Origin{ProgramID: i.ProgramID.ToBase58(), Position: i.Position}
+}
+
+// Account returns the address at the given position of the instruction's account list.
+func (i Instruction) Account(position int) (string, bool) {
+	if position < 0 || position >= len(i.Accounts) {
+		return "", false
+	}
+	return i.Accounts[position], true
+}
+
+// Decoder turns instructions of the programs it is registered for into events. Instructions it
+// does not understand yield no events and no error.
+type Decoder interface {
+	Decode(instruction Instruction) ([]Event, error)
+}
+
+// DecoderFunc adapts a function to the Decoder interface.
+type DecoderFunc func(instruction Instruction) ([]Event, error)
+
+func (f DecoderFunc) Decode(instruction Instruction) ([]Event, error) {
+	return f(instruction)
+}
+
+// ProgramLog is the payload of a "Program data:" log line together with the program that
+// emitted it. Position points at the top-level instruction the program ran under.
+type ProgramLog struct {
+	ProgramID common.PublicKey
+	Position  Position
+	Data      []byte
+}
+
+// LogDecoder turns the data logged by the programs it is registered for into events.
+type LogDecoder interface {
+	DecodeLog(entry ProgramLog) ([]Event, error)
+}
+
+// Registry dispatches instructions and logged program data to the decoders registered for their
+// program.
+type Registry struct {
+	mu          sync.RWMutex
+	decoders    map[common.PublicKey][]Decoder
+	logDecoders map[common.PublicKey][]LogDecoder
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		decoders:    make(map[common.PublicKey][]Decoder),
+		logDecoders: make(map[common.PublicKey][]LogDecoder),
+	}
+}
+
+// NewDefaultRegistry returns a registry with the built-in System, Token, Token-2022, Memo and
+// Stake decoders.
+func NewDefaultRegistry() *Registry {
+	registry := NewRegistry()
+	registry.Register(common.SystemProgramID, DecoderFunc(decodeSystemInstruction))
+	registry.Register(common.TokenProgramID, DecoderFunc(decodeTokenInstruction))
+	registry.Register(common.Token2022ProgramID, DecoderFunc(decodeTokenInstruction))
+	registry.Register(common.MemoProgramID, DecoderFunc(decodeMemoInstruction))
+	registry.Register(legacyMemoProgramID, DecoderFunc(decodeMemoInstruction))
+	registry.Register(common.StakeProgramID, DecoderFunc(decodeStakeInstruction))
+	return registry
+}
+
+// Register adds a decoder for a program. Several decoders may be registered for the same
+// program; all of them see every instruction.
+func (r *Registry) Register(programID common.PublicKey, decoder Decoder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.decoders[programID] = append(r.decoders[programID], decoder)
+}
+
+// RegisterLogDecoder adds a decoder for the data a program logs.
+func (r *Registry) RegisterLogDecoder(programID common.PublicKey, decoder LogDecoder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logDecoders[programID] = append(r.logDecoders[programID], decoder)
+}
+
+// DecodeTransaction dispatches every top-level and inner instruction of the transaction, in
+// execution order, followed by the program data found in its logs, and returns the events
+// decoded from them. Decoder errors are logged and the instruction or log entry is skipped.
+func (r *Registry) DecodeTransaction(tx *client.Transaction) []Event {
+	accountKeys := AccountKeys(tx)
+	tokenAccounts := tokenAccountsByAddress(tx, accountKeys)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var events []Event
+	forEachInstruction(tx, func(compiled types.CompiledInstruction, position Position) {
+		instruction, ok := resolveInstruction(compiled, accountKeys)
+		if !ok {
+			return
+		}
+		instruction.Position = position
+		instruction.TokenAccounts = tokenAccounts
+
+		for _, decoder := range r.decoders[instruction.ProgramID] {
+			decoded, err := decoder.Decode(instruction)
+			if err != nil {
+				log.Warnf("Failed to decode instruction %d of program %s: %v", position.Index, instruction.ProgramID.ToBase58(), err)
+				continue
+			}
+			events = append(events, decoded...)
+		}
+	})
+
+	if tx.Meta == nil {
+		return events
+	}
+	for _, entry := range programLogs(tx.Meta.LogMessages) {
+		for _, decoder := range r.logDecoders[entry.ProgramID] {
+			decoded, err := decoder.DecodeLog(entry)
+			if err != nil {
+				log.Warnf("Failed to decode logged data of program %s: %v", entry.ProgramID.ToBase58(), err)
+				continue
+			}
+			events = append(events, decoded...)
+		}
+	}
+	return events
+}
+
+// forEachInstruction visits the top-level instructions of a transaction, each followed by the
+// inner instructions it invoked, which is the order they executed in.
+func forEachInstruction(tx *client.Transaction, visit func(instruction types.CompiledInstruction, position Position)) {
+	innerByIndex := make(map[uint64][]types.CompiledInstruction)
+	if tx.Meta != nil {
+		for _, inner := range tx.Meta.InnerInstructions {
+			innerByIndex[inner.Index] = append(innerByIndex[inner.Index], inner.Instructions...)
+		}
+	}
+
+	for i, instruction := range tx.Transaction.Message.Instructions {
+		visit(instruction, Position{Index: i})
+		for j, inner := range innerByIndex[uint64(i)] {
+			innerIndex := j
+			visit(inner, Position{Index: i, InnerIndex: &innerIndex})
+		}
+	}
+}
+
+// resolveInstruction maps the indexes of a compiled instruction to addresses. It reports false
+// when any index is outside the account key list.
+func resolveInstruction(compiled types.CompiledInstruction, accountKeys []common.PublicKey) (Instruction, bool) {
+	if compiled.ProgramIDIndex < 0 || compiled.ProgramIDIndex >= len(accountKeys) {
+		return Instruction{}, false
+	}
+
+	accounts := make([]string, 0, len(compiled.Accounts))
+	for _, index := range compiled.Accounts {
+		if index < 0 || index >= len(accountKeys) {
+			return Instruction{}, false
+		}
+		accounts = append(accounts, accountKeys[index].ToBase58())
+	}
+
+	return Instruction{
+		ProgramID: accountKeys[compiled.ProgramIDIndex],
+		Accounts:  accounts,
+		Data:      compiled.Data,
+	}, true
+}
+
+// tokenAccountsByAddress indexes the pre and post token balances by token account address.
+func tokenAccountsByAddress(tx *client.Transaction, accountKeys []common.PublicKey) map[string]TokenAccount {
+	accounts := make(map[string]TokenAccount)
+	if tx.Meta == nil {
+		return accounts
+	}
+
+	for _, balances := range [][]rpc.TransactionMetaTokenBalance{tx.Meta.PreTokenBalances, tx.Meta.PostTokenBalances} {
+		for _, balance := range balances {
+			if balance.AccountIndex >= uint64(len(accountKeys)) {
+				continue
+			}
+			accounts[accountKeys[balance.AccountIndex].ToBase58()] = TokenAccount{
+				Mint:     balance.Mint,
+				Owner:    balance.Owner,
+				Decimals: balance.UITokenAmount.Decimals,
+			}
+		}
+	}
+	return accounts
+}
+
+// IsTokenProgram reports whether the program is SPL Token or Token-2022.
+func IsTokenProgram(programID common.PublicKey) bool {
+	return programID == common.TokenProgramID || programID == common.Token2022ProgramID
+}
+
+// Token accounts share the same base layout under SPL Token and Token-2022. Token-2022 accounts
+// with extensions are longer and carry an account type byte right after the base layout, which
+// tells them apart from mints padded to the same length.
+const (
+	TokenAccountSize        = 165
+	tokenAccountTypeAccount = 2
+)
+
+// IsTokenAccountData reports whether account data owned by a token program is a token account.
+func IsTokenAccountData(data []byte) bool {
+	if len(data) == TokenAccountSize {
+		return true
+	}
+	return len(data) > TokenAccountSize && data[TokenAccountSize] == tokenAccountTypeAccount
+}
@@ -0,0 +1,54 @@
+package instructionDecoder
+
+import (
+	"encoding/binary"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
+)
+
+// Stake program instruction tags, from the StakeInstruction enum of the Solana runtime.
+const (
+	stakeInstructionDelegateStake = 2
+	stakeInstructionWithdraw      = 4
+	stakeInstructionDeactivate    = 5
+)
+
+// decodeStakeInstruction decodes delegations, deactivations and withdrawals of stake accounts. The
+// authority's position among the accounts differs per instruction.
+func decodeStakeInstruction(instruction Instruction) ([]Event, error) {
+	data := instruction.Data
+	if len(data) < 4 {
+		return nil, nil
+	}
+
+	stakeAccount, ok := instruction.Account(0)
+	if !ok {
+		return nil, nil
+	}
+	event := StakeEvent{Origin: instruction.Origin(), StakeAccount: stakeAccount}
+
+	switch binary.LittleEndian.Uint32(data[0:4]) {
+	case stakeInstructionDelegateStake:
+		event.Action = enums.StakeDelegate
+		if event.VoteAccount, ok = instruction.Account(1); !ok {
+			return nil, nil
+		}
+		event.Authority, _ = instruction.Account(5)
+	case stakeInstructionDeactivate:
+		event.Action = enums.StakeDeactivate
+		event.Authority, _ = instruction.Account(2)
+	case stakeInstructionWithdraw:
+		if len(data) < 12 {
+			return nil, nil
+		}
+		event.Action = enums.StakeWithdraw
+		event.Lamports = binary.LittleEndian.Uint64(data[4:12])
+		if event.Recipient, ok = instruction.Account(1); !ok {
+			return nil, nil
+		}
+		event.Authority, _ = instruction.Account(4)
+	default:
+		return nil, nil
+	}
+
+	return []Event{event}, nil
+}
@@ -0,0 +1,56 @@
+package instructionDecoder
+
+import (
+	"encoding/binary"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
+)
+
+// NativeSOLMint is the pseudo mint used for lamport movements so they flow through the same
+// filters and storage as SPL tokens.
+const NativeSOLMint = "NativeSOL"
+
+const NativeSOLDecimals = 9
+
+// decodeSystemInstruction decodes the System program instructions that move lamports: Transfer,
+// TransferWithSeed and CreateAccount.
+func decodeSystemInstruction(instruction Instruction) ([]Event, error) {
+	data := instruction.Data
+	if len(data) < 12 {
+		return nil, nil
+	}
+
+	event := TransferEvent{
+		Origin:   instruction.Origin(),
+		Mint:     NativeSOLMint,
+		Decimals: NativeSOLDecimals,
+		Amount:   binary.LittleEndian.Uint64(data[4:12]),
+	}
+
+	// Source and destination positions differ per instruction; TransferWithSeed puts the base account in between.
+	var sourcePosition, destinationPosition int
+	switch system.Instruction(binary.LittleEndian.Uint32(data[0:4])) {
+	case system.InstructionTransfer:
+		event.Instruction = enums.SystemTransfer
+		sourcePosition, destinationPosition = 0, 1
+	case system.InstructionTransferWithSeed:
+		event.Instruction = enums.SystemTransferWithSeed
+		sourcePosition, destinationPosition = 0, 2
+	case system.InstructionCreateAccount:
+		event.Instruction = enums.SystemCreateAccount
+		sourcePosition, destinationPosition = 0, 1
+	default:
+		return nil, nil
+	}
+
+	var ok bool
+	if event.Source, ok = instruction.Account(sourcePosition); !ok {
+		return nil, nil
+	}
+	if event.Destination, ok = instruction.Account(destinationPosition); !ok {
+		return nil, nil
+	}
+	event.SourceOwner = event.Source
+	event.DestinationOwner = event.Destination
+	return []Event{event}, nil
+}
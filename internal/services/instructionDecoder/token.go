@@ -0,0 +1,115 @@
+package instructionDecoder
+
+import (
+	"encoding/binary"
+	"github.com/blocto/solana-go-sdk/program/token"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
+)
+
+// decodeTokenInstruction decodes the transfer, mint and burn instructions shared by SPL Token and
+// Token-2022. Plain Transfer, MintTo and Burn do not carry decimals and Transfer does not carry
+// the mint, so those are taken from the token balances of the accounts involved.
+func decodeTokenInstruction(instruction Instruction) ([]Event, error) {
+	data := instruction.Data
+	if len(data) < 9 {
+		return nil, nil
+	}
+
+	kind := token.Instruction(data[0])
+	amount := binary.LittleEndian.Uint64(data[1:9])
+
+	checked := kind == token.InstructionTransferChecked || kind == token.InstructionMintToChecked || kind == token.InstructionBurnChecked
+	var decimals uint8
+	if checked {
+		if len(data) < 10 {
+			return nil, nil
+		}
+		decimals = data[9]
+	}
+
+	// known fills in the mint and decimals of an instruction that does not carry them.
+	known := func(mint *string, addresses ...string) {
+		for _, address := range addresses {
+			account, found := instruction.TokenAccounts[address]
+			if !found {
+				continue
+			}
+			if *mint == "" {
+				*mint = account.Mint
+			}
+			if !checked {
+				decimals = account.Decimals
+			}
+			return
+		}
+	}
+	owner := func(address string) string {
+		return instruction.TokenAccounts[address].Owner
+	}
+
+	switch kind {
+	case token.InstructionTransfer, token.InstructionTransferChecked:
+		event := TransferEvent{Origin: instruction.Origin(), Instruction: enums.TokenTransfer, Amount: amount}
+		sourcePosition, destinationPosition := 0, 1
+		if checked {
+			event.Instruction = enums.TokenTransferChecked
+			sourcePosition, destinationPosition = 0, 2
+			mint, ok := instruction.Account(1)
+			if !ok {
+				return nil, nil
+			}
+			event.Mint = mint
+		}
+
+		var ok bool
+		if event.Source, ok = instruction.Account(sourcePosition); !ok {
+			return nil, nil
+		}
+		if event.Destination, ok = instruction.Account(destinationPosition); !ok {
+			return nil, nil
+		}
+		known(&event.Mint, event.Source, event.Destination)
+		event.Decimals = decimals
+		event.SourceOwner = owner(event.Source)
+		event.DestinationOwner = owner(event.Destination)
+		return []Event{event}, nil
+
+	case token.InstructionMintTo, token.InstructionMintToChecked:
+		event := MintEvent{Origin: instruction.Origin(), Instruction: enums.TokenMintTo, Amount: amount}
+		if checked {
+			event.Instruction = enums.TokenMintToChecked
+		}
+
+		var ok bool
+		if event.Mint, ok = instruction.Account(0); !ok {
+			return nil, nil
+		}
+		if event.Destination, ok = instruction.Account(1); !ok {
+			return nil, nil
+		}
+		known(&event.Mint, event.Destination)
+		event.Decimals = decimals
+		event.DestinationOwner = owner(event.Destination)
+		return []Event{event}, nil
+
+	case token.InstructionBurn, token.InstructionBurnChecked:
+		event := BurnEvent{Origin: instruction.Origin(), Instruction: enums.TokenBurn, Amount: amount}
+		if checked {
+			event.Instruction = enums.TokenBurnChecked
+		}
+
+		var ok bool
+		if event.Source, ok = instruction.Account(0); !ok {
+			return nil, nil
+		}
+		if event.Mint, ok = instruction.Account(1); !ok {
+			return nil, nil
+		}
+		known(&event.Mint, event.Source)
+		event.Decimals = decimals
+		event.SourceOwner = owner(event.Source)
+		return []Event{event}, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,40 @@
+package tokenTransactionProcessor
+
+import (
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/amount"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/instructionDecoder"
+	"math/big"
+)
+
+// computeLamportDeltas returns the signed lamport change per account, skipping unchanged
+// accounts. The fee is added back to the fee payer so it is not reported as an outbound transfer.
+func computeLamportDeltas(tx *client.Transaction) []tokenBalanceDelta {
+	meta := tx.Meta
+	if meta == nil || len(meta.PreBalances) != len(meta.PostBalances) {
+		return nil
+	}
+
+	accountKeys := instructionDecoder.AccountKeys(tx)
+	var deltas []tokenBalanceDelta
+	for i := range meta.PreBalances {
+		if i >= len(accountKeys) {
+			break
+		}
+
+		change := meta.PostBalances[i] - meta.PreBalances[i]
+		if i == 0 {
+			change += int64(meta.Fee)
+		}
+		if change == 0 {
+			continue
+		}
+
+		deltas = append(deltas, tokenBalanceDelta{
+			Owner: accountKeys[i].ToBase58(),
+			Mint:  instructionDecoder.NativeSOLMint,
+			Delta: amount.FromBigInt(big.NewInt(change), instructionDecoder.NativeSOLDecimals),
+		})
+	}
+	return deltas
+}
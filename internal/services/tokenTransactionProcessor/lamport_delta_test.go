@@ -0,0 +1,108 @@
+package tokenTransactionProcessor
+
+import (
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/instructionDecoder"
+	"reflect"
+	"testing"
+)
+
+const carol = "4Nd1mBQtrMJVYVfKf2PJy9NZUZdTAsp7D4xWLs4gDB4T"
+
+func lamportTransaction(accounts []string, meta *client.TransactionMeta) *client.Transaction {
+	keys := make([]common.PublicKey, 0, len(accounts))
+	for _, account := range accounts {
+		keys = append(keys, common.PublicKeyFromString(account))
+	}
+	return &client.Transaction{
+		Meta:        meta,
+		Transaction: types.Transaction{Message: types.Message{Accounts: keys}},
+	}
+}
+
+func TestComputeLamportDeltas(t *testing.T) {
+	sol := instructionDecoder.NativeSOLMint
+	tests := []struct {
+		name string
+		tx   *client.Transaction
+		want [][3]string
+	}{
+		{
+			name: "no meta",
+			tx:   lamportTransaction([]string{alice}, nil),
+		},
+		{
+			name: "transfer with the fee added back to the payer",
+			tx: lamportTransaction([]string{alice, bob}, &client.TransactionMeta{
+				Fee:          5000,
+				PreBalances:  []int64{2_000_000_000, 500_000_000},
+				PostBalances: []int64{1_499_995_000, 1_000_000_000},
+			}),
+			want: [][3]string{{alice, sol, "-0.5"}, {bob, sol, "0.5"}},
+		},
+		{
+			name: "fee alone is no movement",
+			tx: lamportTransaction([]string{alice, bob}, &client.TransactionMeta{
+				Fee:          5000,
+				PreBalances:  []int64{1_000_000_000, 500_000_000},
+				PostBalances: []int64{999_995_000, 500_000_000},
+			}),
+		},
+		{
+			name: "fee is only added back to the first account",
+			tx: lamportTransaction([]string{alice, bob, carol}, &client.TransactionMeta{
+				Fee:          5000,
+				PreBalances:  []int64{1_000_000_000, 300_000_000, 0},
+				PostBalances: []int64{999_995_000, 100_000_000, 200_000_000},
+			}),
+			want: [][3]string{{bob, sol, "-0.2"}, {carol, sol, "0.2"}},
+		},
+		{
+			name: "account created and funded",
+			tx: lamportTransaction([]string{alice, carol}, &client.TransactionMeta{
+				Fee:          5000,
+				PreBalances:  []int64{1_000_000_000, 0},
+				PostBalances: []int64{997_955_720, 2_039_280},
+			}),
+			want: [][3]string{{alice, sol, "-0.00203928"}, {carol, sol, "0.00203928"}},
+		},
+		{
+			name: "account closed to its owner",
+			tx: lamportTransaction([]string{alice, carol}, &client.TransactionMeta{
+				Fee:          5000,
+				PreBalances:  []int64{1_000_000_000, 2_039_280},
+				PostBalances: []int64{1_002_034_280, 0},
+			}),
+			want: [][3]string{{alice, sol, "0.00203928"}, {carol, sol, "-0.00203928"}},
+		},
+		{
+			name: "loaded addresses follow the static keys",
+			tx: lamportTransaction([]string{alice}, &client.TransactionMeta{
+				Fee:             5000,
+				PreBalances:     []int64{1_000_000_000, 0},
+				PostBalances:    []int64{899_995_000, 100_000_000},
+				LoadedAddresses: rpc.TransactionLoadedAddresses{Writable: []string{bob}},
+			}),
+			want: [][3]string{{alice, sol, "-0.1"}, {bob, sol, "0.1"}},
+		},
+		{
+			name: "mismatched balance lists are ignored",
+			tx: lamportTransaction([]string{alice, bob}, &client.TransactionMeta{
+				PreBalances:  []int64{1_000_000_000, 0},
+				PostBalances: []int64{900_000_000},
+			}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := deltaStrings(computeLamportDeltas(tt.tx))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("computeLamportDeltas = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package tokenTransactionProcessor
+
+import (
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
+	log "github.com/delaram-gholampoor-sagha/SOLSniffer/internal/logger"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/instructionDecoder"
+	"sort"
+	"strconv"
+)
+
+// tokenTransfer is a single token or lamport movement decoded from an instruction. Source is
+// empty for mints and Destination is empty for burns.
+type tokenTransfer struct {
+	Instruction      enums.TokenInstruction
+	Source           string
+	SourceOwner      string
+	Destination      string
+	DestinationOwner string
+	Mint             string
+	Amount           uint64
+	Decimals         uint8
+	Position         instructionDecoder.Position
+}
+
+// transfersFromEvents picks the events that move funds out of the decoded events. Memos are
+// stored on the movements and program and stake events by saveProgramEvents.
+func transfersFromEvents(hash string, events []instructionDecoder.Event) []tokenTransfer {
+	var transfers []tokenTransfer
+	for _, event := range events {
+		switch e := event.(type) {
+		case instructionDecoder.TransferEvent:
+			transfers = append(transfers, tokenTransfer{
+				Instruction:      e.Instruction,
+				Source:           e.Source,
+				SourceOwner:      e.SourceOwner,
+				Destination:      e.Destination,
+				DestinationOwner: e.DestinationOwner,
+				Mint:             e.Mint,
+				Amount:           e.Amount,
+				Decimals:         e.Decimals,
+				Position:         e.Position,
+			})
+		case instructionDecoder.MintEvent:
+			transfers = append(transfers, tokenTransfer{
+				Instruction:      e.Instruction,
+				Destination:      e.Destination,
+				DestinationOwner: e.DestinationOwner,
+				Mint:             e.Mint,
+				Amount:           e.Amount,
+				Decimals:         e.Decimals,
+				Position:         e.Position,
+			})
+		case instructionDecoder.BurnEvent:
+			transfers = append(transfers, tokenTransfer{
+				Instruction: e.Instruction,
+				Source:      e.Source,
+				SourceOwner: e.SourceOwner,
+				Mint:        e.Mint,
+				Amount:      e.Amount,
+				Decimals:    e.Decimals,
+				Position:    e.Position,
+			})
+		case instructionDecoder.MemoEvent, instructionDecoder.ProgramEvent, instructionDecoder.StakeEvent:
+			// Stored with the movements and by saveProgramEvents.
+		default:
+			log.Debugf("Transaction %s: %s event from program %s", hash, event.Kind(), event.GetOrigin().ProgramID)
+		}
+	}
+	return transfers
+}
+
+// memosFromEvents returns the text of the memos attached to the transaction in instruction order.
+func memosFromEvents(events []instructionDecoder.Event) []string {
+	var memos []string
+	for _, event := range events {
+		if memo, ok := event.(instructionDecoder.MemoEvent); ok {
+			memos = append(memos, memo.Memo)
+		}
+	}
+	return memos
+}
+
+// stakeProgram is the program name stake events are stored under.
+const stakeProgram = "stake"
+
+// asProgramEvent returns the events stored as program events. Stake events have no IDL and are
+// given the same shape, named after their action.
+func asProgramEvent(event instructionDecoder.Event) (instructionDecoder.ProgramEvent, bool) {
+	switch e := event.(type) {
+	case instructionDecoder.ProgramEvent:
+		return e, true
+	case instructionDecoder.StakeEvent:
+		accounts := map[string]string{"stake_account": e.StakeAccount}
+		for name, account := range map[string]string{"authority": e.Authority, "vote_account": e.VoteAccount, "recipient": e.Recipient} {
+			if account != "" {
+				accounts[name] = account
+			}
+		}
+		data := map[string]any{}
+		if e.Action == enums.StakeWithdraw {
+			data["lamports"] = strconv.FormatUint(e.Lamports, 10)
+		}
+		return instructionDecoder.ProgramEvent{
+			Origin:   e.Origin,
+			Program:  stakeProgram,
+			Name:     string(e.Action),
+			Source:   enums.ProgramEventInstruction,
+			Accounts: accounts,
+			Data:     data,
+		}, true
+	}
+	return instructionDecoder.ProgramEvent{}, false
+}
+
+// sortMovements orders movements in the order their instructions executed: each top-level
+// instruction followed by its inner instructions. Movements derived from balance deltas have no
+// instruction and come last, in the order they were found.
+func sortMovements(movements []entity.Transaction) {
+	sort.SliceStable(movements, func(i, j int) bool {
+		a, b := movements[i], movements[j]
+		if a.InstructionIndex == nil || b.InstructionIndex == nil {
+			return a.InstructionIndex != nil && b.InstructionIndex == nil
+		}
+		if *a.InstructionIndex != *b.InstructionIndex {
+			return *a.InstructionIndex < *b.InstructionIndex
+		}
+		if a.InnerInstructionIndex == nil || b.InnerInstructionIndex == nil {
+			return a.InnerInstructionIndex == nil && b.InnerInstructionIndex != nil
+		}
+		return *a.InnerInstructionIndex < *b.InnerInstructionIndex
+	})
+}
@@ -0,0 +1,42 @@
+package tokenTransactionProcessor
+
+import (
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+	"reflect"
+	"testing"
+)
+
+func movementAt(name string, index int, innerIndex ...int) entity.Transaction {
+	movement := entity.Transaction{Instruction: name}
+	if index >= 0 {
+		movement.InstructionIndex = &index
+	}
+	if len(innerIndex) > 0 {
+		movement.InnerInstructionIndex = &innerIndex[0]
+	}
+	return movement
+}
+
+func TestSortMovements(t *testing.T) {
+	movements := []entity.Transaction{
+		movementAt("token 2.1", 2, 1),
+		movementAt("token 0", 0),
+		movementAt("delta usdc", -1),
+		movementAt("system 1", 1),
+		movementAt("token 2.0", 2, 0),
+		movementAt("delta sol", -1),
+		movementAt("system 2", 2),
+		movementAt("system 0.3", 0, 3),
+	}
+
+	sortMovements(movements)
+
+	var got []string
+	for _, movement := range movements {
+		got = append(got, movement.Instruction)
+	}
+	want := []string{"token 0", "system 0.3", "system 1", "system 2", "token 2.0", "token 2.1", "delta usdc", "delta sol"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sorted movements = %v, want %v", got, want)
+	}
+}
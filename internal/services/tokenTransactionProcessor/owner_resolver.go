@@ -0,0 +1,111 @@
+package tokenTransactionProcessor
+
+import (
+	"context"
+	"fmt"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/instructionDecoder"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/watchlist"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/transport/solanaClient"
+	"sync"
+)
+
+const tokenAccountOwnerOffset = 32
+
+// ownerCacheCount is how many resolved accounts are remembered before the oldest are forgotten.
+const ownerCacheCount = 50000
+
+// ownerResolver maps token accounts to the wallets that own them. Owners reported in the
+// transaction meta are used first, then the associated token accounts of monitored wallets, and
+// finally the account itself is fetched over RPC. RPC results for existing accounts are cached,
+// including accounts that turned out not to be token accounts. Missing accounts are not cached
+// since they may be created later.
+type ownerResolver struct {
+	solanaClient *solanaClient.SolanaClient
+	watchlist    *watchlist.Service
+
+	cache *ownerCache
+}
+
+func newOwnerResolver(solanaClient *solanaClient.SolanaClient, watchlist *watchlist.Service) *ownerResolver {
+	return &ownerResolver{
+		solanaClient: solanaClient,
+		watchlist:    watchlist,
+		cache:        newOwnerCache(ownerCacheCount),
+	}
+}
+
+// Resolve returns the wallet owning the token account, or an empty string if it cannot be
+// determined or the address is not a token account.
+func (r *ownerResolver) Resolve(ctx context.Context, address string) (string, error) {
+	if address == "" {
+		return "", nil
+	}
+	if owner, ok := r.watchlist.OwnerOf(address); ok {
+		return owner, nil
+	}
+
+	owner, ok := r.cache.get(address)
+	if ok {
+		return owner, nil
+	}
+
+	if r.solanaClient == nil {
+		return "", nil
+	}
+
+	account, err := r.solanaClient.GetAccountInfo(ctx, address)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch account %s: %w", address, err)
+	}
+	if account.Owner == (common.PublicKey{}) {
+		return "", nil
+	}
+
+	if instructionDecoder.IsTokenProgram(account.Owner) && instructionDecoder.IsTokenAccountData(account.Data) {
+		owner = common.PublicKeyFromBytes(account.Data[tokenAccountOwnerOffset : tokenAccountOwnerOffset+32]).ToBase58()
+	}
+
+	r.cache.add(address, owner)
+	return owner, nil
+}
+
+// ownerCache remembers the owners of the most recently resolved accounts up to a fixed count.
+type ownerCache struct {
+	mu     sync.Mutex
+	owners map[string]string
+	order  []string
+	next   int
+}
+
+func newOwnerCache(count int) *ownerCache {
+	return &ownerCache{
+		owners: make(map[string]string, count),
+		order:  make([]string, count),
+	}
+}
+
+func (c *ownerCache) get(address string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	owner, ok := c.owners[address]
+	return owner, ok
+}
+
+// add remembers the owner of the account, forgetting the oldest account if full.
+func (c *ownerCache) add(address, owner string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.owners[address]; ok {
+		c.owners[address] = owner
+		return
+	}
+	if oldest := c.order[c.next]; oldest != "" {
+		delete(c.owners, oldest)
+	}
+	c.order[c.next] = address
+	c.next = (c.next + 1) % len(c.order)
+	c.owners[address] = owner
+}
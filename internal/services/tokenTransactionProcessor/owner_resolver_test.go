@@ -0,0 +1,30 @@
+package tokenTransactionProcessor
+
+import "testing"
+
+func TestOwnerCache(t *testing.T) {
+	cache := newOwnerCache(2)
+	cache.add("a", alice)
+	cache.add("b", "")
+	cache.add("a", bob)
+
+	if owner, ok := cache.get("a"); !ok || owner != bob {
+		t.Errorf("get(a) = %q, %v, want %q, true", owner, ok, bob)
+	}
+	if owner, ok := cache.get("b"); !ok || owner != "" {
+		t.Errorf("get(b) = %q, %v, want empty, true", owner, ok)
+	}
+
+	cache.add("c", carol)
+	if _, ok := cache.get("a"); ok {
+		t.Error("get(a) found the oldest account after the cache was full")
+	}
+	for address, want := range map[string]string{"b": "", "c": carol} {
+		if owner, ok := cache.get(address); !ok || owner != want {
+			t.Errorf("get(%s) = %q, %v, want %q, true", address, owner, ok, want)
+		}
+	}
+	if len(cache.owners) != 2 {
+		t.Errorf("cache holds %d accounts, want 2", len(cache.owners))
+	}
+}
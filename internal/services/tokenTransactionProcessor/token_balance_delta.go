@@ -0,0 +1,94 @@
+package tokenTransactionProcessor
+
+import (
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/amount"
+	"sort"
+)
+
+// tokenBalanceDelta is the net change of a wallet's holdings of one mint within a transaction.
+type tokenBalanceDelta struct {
+	Owner string
+	Mint  string
+	Delta amount.Amount
+}
+
+// tokenBalanceKey identifies a token account snapshot. The owner is part of the key so an
+// authority change inside the transaction is seen as one account closing and another opening.
+type tokenBalanceKey struct {
+	accountIndex uint64
+	owner        string
+}
+
+type ownerMintKey struct {
+	owner string
+	mint  string
+}
+
+// computeTokenBalanceDeltas pairs the pre and post token balances of a transaction and returns
+// the signed change per (owner, mint), skipping pairs that net to zero. Accounts missing from the
+// pre balances were created by the transaction and accounts missing from the post balances were
+// closed by it, so the missing side counts as zero.
+func computeTokenBalanceDeltas(meta *client.TransactionMeta) []tokenBalanceDelta {
+	if meta == nil {
+		return nil
+	}
+
+	pre := indexTokenBalances(meta.PreTokenBalances)
+	post := indexTokenBalances(meta.PostTokenBalances)
+
+	totals := make(map[ownerMintKey]*tokenBalanceDelta)
+	apply := func(balance rpc.TransactionMetaTokenBalance, sign int) {
+		value, err := amount.Parse(balance.UITokenAmount.Amount, balance.UITokenAmount.Decimals)
+		if err != nil {
+			return
+		}
+		key := ownerMintKey{owner: balance.Owner, mint: balance.Mint}
+		total, found := totals[key]
+		if !found {
+			total = &tokenBalanceDelta{
+				Owner: balance.Owner,
+				Mint:  balance.Mint,
+				Delta: amount.New(0, balance.UITokenAmount.Decimals),
+			}
+			totals[key] = total
+		}
+		if sign < 0 {
+			total.Delta = total.Delta.Sub(value)
+		} else {
+			total.Delta = total.Delta.Add(value)
+		}
+	}
+
+	for _, balance := range post {
+		apply(balance, 1)
+	}
+	for _, balance := range pre {
+		apply(balance, -1)
+	}
+
+	deltas := make([]tokenBalanceDelta, 0, len(totals))
+	for _, total := range totals {
+		if total.Owner == "" || total.Delta.Sign() == 0 {
+			continue
+		}
+		deltas = append(deltas, *total)
+	}
+
+	sort.Slice(deltas, func(i, j int) bool {
+		if deltas[i].Owner != deltas[j].Owner {
+			return deltas[i].Owner < deltas[j].Owner
+		}
+		return deltas[i].Mint < deltas[j].Mint
+	})
+	return deltas
+}
+
+func indexTokenBalances(balances []rpc.TransactionMetaTokenBalance) map[tokenBalanceKey]rpc.TransactionMetaTokenBalance {
+	indexed := make(map[tokenBalanceKey]rpc.TransactionMetaTokenBalance, len(balances))
+	for _, balance := range balances {
+		indexed[tokenBalanceKey{accountIndex: balance.AccountIndex, owner: balance.Owner}] = balance
+	}
+	return indexed
+}
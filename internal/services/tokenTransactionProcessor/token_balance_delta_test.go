@@ -0,0 +1,161 @@
+package tokenTransactionProcessor
+
+import (
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"reflect"
+	"testing"
+)
+
+const (
+	usdcMint = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+	bonkMint = "DezXAZ8z7PnrnRJjz3wXBoRgixCa6xjnB7YaB1pPB263"
+	alice    = "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM"
+	bob      = "HN7cABqLq46Es1jh92dQQisAq662SmxELLLsHHe4YWrH"
+)
+
+func tokenBalance(accountIndex uint64, owner, mint, raw string, decimals uint8) rpc.TransactionMetaTokenBalance {
+	return rpc.TransactionMetaTokenBalance{
+		AccountIndex:  accountIndex,
+		Owner:         owner,
+		Mint:          mint,
+		UITokenAmount: rpc.TokenAccountBalance{Amount: raw, Decimals: decimals},
+	}
+}
+
+// deltaStrings renders deltas as owner, mint and signed amount for comparison.
+func deltaStrings(deltas []tokenBalanceDelta) [][3]string {
+	var rendered [][3]string
+	for _, delta := range deltas {
+		rendered = append(rendered, [3]string{delta.Owner, delta.Mint, delta.Delta.String()})
+	}
+	return rendered
+}
+
+func TestComputeTokenBalanceDeltas(t *testing.T) {
+	tests := []struct {
+		name string
+		meta *client.TransactionMeta
+		want [][3]string
+	}{
+		{
+			name: "no meta",
+		},
+		{
+			name: "transfer between two wallets",
+			meta: &client.TransactionMeta{
+				PreTokenBalances: []rpc.TransactionMetaTokenBalance{
+					tokenBalance(1, alice, usdcMint, "5000000", 6),
+					tokenBalance(2, bob, usdcMint, "1000000", 6),
+				},
+				PostTokenBalances: []rpc.TransactionMetaTokenBalance{
+					tokenBalance(1, alice, usdcMint, "3500000", 6),
+					tokenBalance(2, bob, usdcMint, "2500000", 6),
+				},
+			},
+			want: [][3]string{{alice, usdcMint, "-1.5"}, {bob, usdcMint, "1.5"}},
+		},
+		{
+			name: "account created by the transaction",
+			meta: &client.TransactionMeta{
+				PreTokenBalances: []rpc.TransactionMetaTokenBalance{
+					tokenBalance(1, alice, usdcMint, "5000000", 6),
+				},
+				PostTokenBalances: []rpc.TransactionMetaTokenBalance{
+					tokenBalance(1, alice, usdcMint, "4000000", 6),
+					tokenBalance(3, bob, usdcMint, "1000000", 6),
+				},
+			},
+			want: [][3]string{{alice, usdcMint, "-1"}, {bob, usdcMint, "1"}},
+		},
+		{
+			name: "account closed by the transaction",
+			meta: &client.TransactionMeta{
+				PreTokenBalances: []rpc.TransactionMetaTokenBalance{
+					tokenBalance(1, alice, usdcMint, "250000", 6),
+					tokenBalance(2, bob, usdcMint, "0", 6),
+				},
+				PostTokenBalances: []rpc.TransactionMetaTokenBalance{
+					tokenBalance(2, bob, usdcMint, "250000", 6),
+				},
+			},
+			want: [][3]string{{alice, usdcMint, "-0.25"}, {bob, usdcMint, "0.25"}},
+		},
+		{
+			name: "several accounts of one owner are summed",
+			meta: &client.TransactionMeta{
+				PreTokenBalances: []rpc.TransactionMetaTokenBalance{
+					tokenBalance(1, alice, usdcMint, "1000000", 6),
+					tokenBalance(2, alice, usdcMint, "0", 6),
+				},
+				PostTokenBalances: []rpc.TransactionMetaTokenBalance{
+					tokenBalance(1, alice, usdcMint, "0", 6),
+					tokenBalance(2, alice, usdcMint, "400000", 6),
+				},
+			},
+			want: [][3]string{{alice, usdcMint, "-0.6"}},
+		},
+		{
+			name: "moving between own accounts nets to nothing",
+			meta: &client.TransactionMeta{
+				PreTokenBalances: []rpc.TransactionMetaTokenBalance{
+					tokenBalance(1, alice, usdcMint, "1000000", 6),
+					tokenBalance(2, alice, usdcMint, "0", 6),
+				},
+				PostTokenBalances: []rpc.TransactionMetaTokenBalance{
+					tokenBalance(1, alice, usdcMint, "0", 6),
+					tokenBalance(2, alice, usdcMint, "1000000", 6),
+				},
+			},
+		},
+		{
+			name: "mints are kept apart",
+			meta: &client.TransactionMeta{
+				PreTokenBalances: []rpc.TransactionMetaTokenBalance{
+					tokenBalance(1, alice, usdcMint, "2000000", 6),
+					tokenBalance(2, alice, bonkMint, "0", 5),
+				},
+				PostTokenBalances: []rpc.TransactionMetaTokenBalance{
+					tokenBalance(1, alice, usdcMint, "1000000", 6),
+					tokenBalance(2, alice, bonkMint, "12345678", 5),
+				},
+			},
+			want: [][3]string{{alice, bonkMint, "123.45678"}, {alice, usdcMint, "-1"}},
+		},
+		{
+			name: "authority change is a close and an open",
+			meta: &client.TransactionMeta{
+				PreTokenBalances: []rpc.TransactionMetaTokenBalance{
+					tokenBalance(1, alice, usdcMint, "3000000", 6),
+				},
+				PostTokenBalances: []rpc.TransactionMetaTokenBalance{
+					tokenBalance(1, bob, usdcMint, "3000000", 6),
+				},
+			},
+			want: [][3]string{{alice, usdcMint, "-3"}, {bob, usdcMint, "3"}},
+		},
+		{
+			name: "balances without owner or with invalid amounts are ignored",
+			meta: &client.TransactionMeta{
+				PreTokenBalances: []rpc.TransactionMetaTokenBalance{
+					tokenBalance(1, "", usdcMint, "1000000", 6),
+					tokenBalance(2, bob, usdcMint, "not a number", 6),
+				},
+				PostTokenBalances: []rpc.TransactionMetaTokenBalance{
+					tokenBalance(1, "", usdcMint, "0", 6),
+					tokenBalance(2, bob, usdcMint, "1000000", 6),
+				},
+			},
+			want: [][3]string{{bob, usdcMint, "1"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := deltaStrings(computeTokenBalanceDeltas(tt.meta))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("computeTokenBalanceDeltas = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,105 +2,332 @@ package tokenTransactionProcessor
 
 import (
 	"context"
-	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/rpc"
 	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/contracts/repositories"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
 	log "github.com/delaram-gholampoor-sagha/SOLSniffer/internal/logger"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/amount"
 	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
-	"math"
-	"strconv"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/instructionDecoder"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/watchlist"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/transport/solanaClient"
+	"github.com/mr-tron/base58"
 	"time"
 )
 
 type Service struct {
 	repo             repositories.Transaction
+	eventRepo        repositories.ProgramEvent
 	monitoredTokens  map[string]bool
-	monitoredWallets map[string]bool
+	monitoredWallets *watchlist.Service
+	owners           *ownerResolver
+	decoders         *instructionDecoder.Registry
 }
 
-func New(repo repositories.Transaction, tokens, wallets []string) *Service {
+func New(repo repositories.Transaction, eventRepo repositories.ProgramEvent, solanaClient *solanaClient.SolanaClient, decoders *instructionDecoder.Registry, tokens []string, wallets *watchlist.Service) *Service {
 	tokenSet := make(map[string]bool)
 
 	// Add Native SOL explicitly
-	const nativeSOLMint = "NativeSOL"
-	tokenSet[nativeSOLMint] = true
+	tokenSet[instructionDecoder.NativeSOLMint] = true
 
 	// Add other tokens
 	for _, token := range tokens {
 		tokenSet[token] = true
 	}
 
-	walletSet := make(map[string]bool)
-	for _, wallet := range wallets {
-		walletSet[wallet] = true
-	}
-
 	return &Service{
 		repo:             repo,
+		eventRepo:        eventRepo,
 		monitoredTokens:  tokenSet,
-		monitoredWallets: walletSet,
+		monitoredWallets: wallets,
+		owners:           newOwnerResolver(solanaClient, wallets),
+		decoders:         decoders,
 	}
 }
 
-func (s *Service) ProcessTransaction(ctx context.Context, txDetails *client.Transaction) error {
+// TransactionContext carries what the caller knows about a transaction beyond its RPC payload.
+// TransactionIndex is only known when the transaction was read from a block.
+type TransactionContext struct {
+	Commitment       rpc.Commitment
+	TransactionIndex *int
+}
+
+// ProcessTransaction stores the movements of monitored tokens and wallets in the transaction and
+// the program events involving them. Every movement and event is attempted; the errors of those
+// that could not be saved are returned together, so the caller can process the transaction again.
+func (s *Service) ProcessTransaction(ctx context.Context, txDetails *client.Transaction, txContext TransactionContext) error {
 	if len(txDetails.Transaction.Signatures) == 0 {
 		return fmt.Errorf("no signatures found in transaction")
 	}
 
-	hash := hex.EncodeToString(txDetails.Transaction.Signatures[0])
+	base := newTransactionEntity(txDetails, txContext)
+	hash := base.Hash
 
-	if len(txDetails.Transaction.Message.Accounts) < 2 {
-		return fmt.Errorf("not enough accounts in transaction message")
+	// Instructions of a failed transaction were rolled back, so only balance deltas are trusted.
+	var transfers []tokenTransfer
+	var errs []error
+	if base.Success {
+		events := s.decoders.DecodeTransaction(txDetails)
+		transfers = transfersFromEvents(hash, events)
+		base.Memos = memosFromEvents(events)
+		errs = append(errs, s.saveProgramEvents(ctx, txDetails, base, events)...)
+	}
+	deltas := append(computeTokenBalanceDeltas(txDetails.Meta), computeLamportDeltas(txDetails)...)
+	if len(transfers) == 0 && len(deltas) == 0 {
+		log.Debugf("Transaction %s has no token or SOL transfers; skipping", hash)
+		return errors.Join(errs...)
 	}
 
-	source := txDetails.Transaction.Message.Accounts[0].ToBase58()
-	destination := txDetails.Transaction.Message.Accounts[1].ToBase58()
+	// Balance deltas only fill in for wallets whose movements the instruction decoder could not explain.
+	explained := make(map[ownerMintKey]bool)
+	var movements []entity.Transaction
 
-	if len(txDetails.Meta.PreTokenBalances) == 0 {
-		log.Warnf("Transaction %s has no token balances; skipping", hash)
-		return nil
-	}
+	for _, transfer := range transfers {
+		token := transfer.Mint
 
-	for _, balance := range txDetails.Meta.PreTokenBalances {
-		amountStr := balance.UITokenAmount.Amount
-		amount, err := strconv.ParseFloat(amountStr, 64)
-		if err != nil {
-			log.Errorf("Failed to parse amount: %s", amountStr)
+		if !s.monitoredTokens[token] {
+			log.Infof("Transaction %s with token %s does not match token filters", hash, token)
 			continue
 		}
 
-		amount /= math.Pow10(int(balance.UITokenAmount.Decimals))
-		token := balance.Mint
+		// Token balances usually carry the owners; fall back to the resolver for the rest.
+		if transfer.SourceOwner == "" {
+			transfer.SourceOwner = s.resolveOwner(ctx, hash, transfer.Source)
+		}
+		if transfer.DestinationOwner == "" {
+			transfer.DestinationOwner = s.resolveOwner(ctx, hash, transfer.Destination)
+		}
 
-		isNativeSOL := token == "NativeSOL"
-		if !isNativeSOL && !s.monitoredTokens[token] {
-			log.Infof("Transaction %s with token %s does not match token filters", hash, token)
+		explained[ownerMintKey{owner: transfer.SourceOwner, mint: token}] = true
+		explained[ownerMintKey{owner: transfer.DestinationOwner, mint: token}] = true
+
+		var directions []enums.Direction
+		if s.acceptsWallet(enums.DirectionInbound, transfer.Destination, transfer.DestinationOwner) {
+			directions = append(directions, enums.DirectionInbound)
+		}
+		if s.acceptsWallet(enums.DirectionOutbound, transfer.Source, transfer.SourceOwner) {
+			directions = append(directions, enums.DirectionOutbound)
+		}
+		if len(directions) == 0 {
+			log.Infof("Transaction %s from %s to %s does not match wallet filters", hash, transfer.Source, transfer.Destination)
 			continue
 		}
 
-		if !s.monitoredWallets[destination] {
-			log.Infof("Transaction %s with destination %s does not match wallet filters", hash, destination)
+		// A transfer between two monitored wallets is stored once as a withdrawal and once as a deposit.
+		for _, direction := range directions {
+			transaction := base
+			instructionIndex := transfer.Position.Index
+			transaction.InstructionIndex = &instructionIndex
+			transaction.InnerInstructionIndex = transfer.Position.InnerIndex
+			transaction.Instruction = string(transfer.Instruction)
+			transaction.Direction = direction
+			transaction.Source = transfer.Source
+			transaction.SourceOwner = transfer.SourceOwner
+			transaction.Destination = transfer.Destination
+			transaction.DestinationOwner = transfer.DestinationOwner
+			transaction.Amount = amount.New(transfer.Amount, transfer.Decimals)
+			transaction.RawAmount = transaction.Amount.RawString()
+			transaction.Decimals = transfer.Decimals
+			transaction.TokenMint = token
+			movements = append(movements, transaction)
+		}
+	}
+
+	for _, delta := range deltas {
+		direction := enums.DirectionOutbound
+		if delta.Delta.Sign() > 0 {
+			direction = enums.DirectionInbound
+		}
+
+		if !s.monitoredTokens[delta.Mint] || !s.acceptsWallet(direction, delta.Owner) {
+			continue
+		}
+		if explained[ownerMintKey{owner: delta.Owner, mint: delta.Mint}] {
 			continue
 		}
 
-		transaction := &entity.Transaction{
-			Hash:        hash,
-			Source:      source,
-			Destination: destination,
-			Amount:      amount,
-			TokenMint:   token,
-			Timestamp:   time.Now(),
+		transaction := base
+		transaction.Instruction = string(enums.TokenBalanceChange)
+		transaction.Direction = direction
+		transaction.Amount = delta.Delta.Abs()
+		transaction.RawAmount = transaction.Amount.RawString()
+		transaction.Decimals = transaction.Amount.Decimals()
+		transaction.TokenMint = delta.Mint
+		if direction == enums.DirectionInbound {
+			transaction.Destination = delta.Owner
+			transaction.DestinationOwner = delta.Owner
+		} else {
+			transaction.Source = delta.Owner
+			transaction.SourceOwner = delta.Owner
+		}
+		movements = append(movements, transaction)
+	}
+
+	sortMovements(movements)
+	for i := range movements {
+		if err := s.save(ctx, &movements[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// saveProgramEvents stores the generic events decoded from program IDLs, and stake events, whose
+// instruction involves a monitored wallet or one of its token accounts. Events are numbered in the order they
+// were decoded, which identifies them when the transaction is processed again. It returns the
+// errors of the events that could not be saved.
+func (s *Service) saveProgramEvents(ctx context.Context, txDetails *client.Transaction, base entity.Transaction, events []instructionDecoder.Event) []error {
+	var errs []error
+	var accountKeys []common.PublicKey
+	eventIndex := -1
+	for _, event := range events {
+		programEvent, ok := asProgramEvent(event)
+		if !ok {
+			continue
 		}
+		eventIndex++
 
-		err = s.repo.Save(ctx, transaction)
-		if err != nil {
-			log.Errorf("Failed to save transaction %s", hash)
+		// Logged events carry no accounts; they are attributed to the top-level instruction
+		// that emitted them
+		var accounts []string
+		if programEvent.Source == enums.ProgramEventLog {
+			if accountKeys == nil {
+				accountKeys = instructionDecoder.AccountKeys(txDetails)
+			}
+			accounts = instructionAccounts(txDetails, accountKeys, programEvent.Position.Index)
+		} else {
+			for _, account := range programEvent.Accounts {
+				accounts = append(accounts, account)
+			}
+		}
+		if !s.involvesWatchlist(accounts) {
+			log.Debugf("Transaction %s: %s event %s does not involve a monitored wallet", base.Hash, programEvent.Program, programEvent.Name)
 			continue
 		}
 
-		log.Infof("Transaction %s with token %s processed successfully", hash, token)
+		record := &entity.ProgramEvent{
+			Hash:                  base.Hash,
+			Slot:                  base.Slot,
+			BlockTime:             base.BlockTime,
+			InstructionIndex:      programEvent.Position.Index,
+			InnerInstructionIndex: programEvent.Position.InnerIndex,
+			EventIndex:            eventIndex,
+			ProgramID:             programEvent.ProgramID,
+			Program:               programEvent.Program,
+			Name:                  programEvent.Name,
+			Source:                programEvent.Source,
+			Accounts:              programEvent.Accounts,
+			Data:                  programEvent.Data,
+			Commitment:            base.Commitment,
+			Timestamp:             base.Timestamp,
+		}
+		if err := s.eventRepo.Save(ctx, record); err != nil {
+			log.Errorf("Failed to save %s event %s of transaction %s: %v", programEvent.Program, programEvent.Name, base.Hash, err)
+			errs = append(errs, fmt.Errorf("failed to save %s event %s: %w", programEvent.Program, programEvent.Name, err))
+			continue
+		}
+		log.Infof("Transaction %s: %s event %s saved", base.Hash, programEvent.Program, programEvent.Name)
 	}
+	return errs
+}
+
+// newTransactionEntity fills the fields shared by every movement stored for a transaction.
+func newTransactionEntity(txDetails *client.Transaction, txContext TransactionContext) entity.Transaction {
+	transaction := entity.Transaction{
+		SchemaVersion:    entity.TransactionSchemaVersion,
+		Hash:             base58.Encode(txDetails.Transaction.Signatures[0]),
+		Version:          string(txDetails.Version()),
+		Slot:             txDetails.Slot,
+		TransactionIndex: txContext.TransactionIndex,
+		Success:          true,
+		Commitment:       string(txContext.Commitment),
+		Timestamp:        time.Now(),
+	}
+
+	if txDetails.BlockTime != nil {
+		blockTime := time.Unix(*txDetails.BlockTime, 0).UTC()
+		transaction.BlockTime = &blockTime
+	}
+
+	if meta := txDetails.Meta; meta != nil {
+		transaction.Fee = meta.Fee
+		transaction.ComputeUnitsConsumed = meta.ComputeUnitsConsumed
+		if meta.Err != nil {
+			transaction.Success = false
+			transaction.Error = formatTransactionError(meta.Err)
+		}
+	}
+	return transaction
+}
+
+// formatTransactionError renders the RPC error value, which is usually a JSON object such as
+// {"InstructionError":[0,"InvalidAccountData"]}.
+func formatTransactionError(txErr any) string {
+	encoded, err := json.Marshal(txErr)
+	if err != nil {
+		return fmt.Sprintf("%v", txErr)
+	}
+	return string(encoded)
+}
+
+func (s *Service) resolveOwner(ctx context.Context, hash, address string) string {
+	owner, err := s.owners.Resolve(ctx, address)
+	if err != nil {
+		log.Warnf("Transaction %s: failed to resolve owner of %s: %v", hash, address, err)
+	}
+	return owner
+}
+
+// acceptsWallet reports whether any of the addresses is a monitored wallet that records movements in the given direction.
+func (s *Service) acceptsWallet(direction enums.Direction, addresses ...string) bool {
+	for _, address := range addresses {
+		if setting, ok := s.monitoredWallets.Direction(address); ok && setting.Allows(direction) {
+			return true
+		}
+	}
+	return false
+}
+
+// involvesWatchlist reports whether any of the addresses is a monitored wallet or one of its
+// token accounts.
+func (s *Service) involvesWatchlist(addresses []string) bool {
+	for _, address := range addresses {
+		if _, ok := s.monitoredWallets.Direction(address); ok {
+			return true
+		}
+		if _, ok := s.monitoredWallets.OwnerOf(address); ok {
+			return true
+		}
+	}
+	return false
+}
 
+// instructionAccounts returns the addresses of the accounts of a top-level instruction.
+func instructionAccounts(txDetails *client.Transaction, accountKeys []common.PublicKey, index int) []string {
+	instructions := txDetails.Transaction.Message.Instructions
+	if index < 0 || index >= len(instructions) {
+		return nil
+	}
+
+	var accounts []string
+	for _, key := range instructions[index].Accounts {
+		if key >= 0 && key < len(accountKeys) {
+			accounts = append(accounts, accountKeys[key].ToBase58())
+		}
+	}
+	return accounts
+}
+
+func (s *Service) save(ctx context.Context, transaction *entity.Transaction) error {
+	if err := s.repo.Save(ctx, transaction); err != nil {
+		log.Errorf("Failed to save transaction %s: %v", transaction.Hash, err)
+		return fmt.Errorf("failed to save %s movement of token %s: %w", transaction.Direction, transaction.TokenMint, err)
+	}
+	log.Infof("Transaction %s with token %s processed successfully", transaction.Hash, transaction.TokenMint)
 	return nil
 }
@@ -7,17 +7,23 @@ import (
 	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/request"
 	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/tokenTransactionProcessor"
 	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/transport/solanaClient"
+	"sync"
 )
 
+// recentSignatureCount is how many signatures are remembered to skip repeated notifications.
+const recentSignatureCount = 10000
+
 type Service struct {
 	solanaClient       *solanaClient.SolanaClient
 	transactionService *tokenTransactionProcessor.Service
+	recent             *recentSignatures
 }
 
 func New(solanaClient *solanaClient.SolanaClient, transactionService *tokenTransactionProcessor.Service) *Service {
 	return &Service{
 		solanaClient:       solanaClient,
 		transactionService: transactionService,
+		recent:             newRecentSignatures(recentSignatureCount),
 	}
 }
 
@@ -28,8 +34,16 @@ func (t *Service) ProcessMessage(ctx context.Context, message []byte) error {
 	}
 
 	// Process the transaction signature
-	signature := txLog.Params.Result.Signature
+	signature := txLog.Params.Result.Value.Signature
+
+	// A transaction mentioning several watched addresses is streamed once per subscription. It
+	// is claimed before processing so concurrent notifications skip it, and released on failure
+	// so a later notification can process it.
+	if !t.recent.add(signature) {
+		return nil
+	}
 	if err := t.processTransaction(ctx, signature); err != nil {
+		t.recent.remove(signature)
 		return err
 	}
 	return nil
@@ -44,10 +58,62 @@ func (t *Service) processTransaction(ctx context.Context, signature string) erro
 		return fmt.Errorf("transaction %s not found", signature)
 	}
 
-	if err := t.transactionService.ProcessTransaction(ctx, txDetails); err != nil {
+	txContext := tokenTransactionProcessor.TransactionContext{Commitment: t.solanaClient.Commitment()}
+	if err := t.transactionService.ProcessTransaction(ctx, txDetails, txContext); err != nil {
 		return fmt.Errorf("failed to process transaction %s: %w", signature, err)
 	}
 
 	log.Infof("Transaction %s processed successfully", signature)
 	return nil
 }
+
+// recentSignatures remembers the most recent signatures up to a fixed count.
+type recentSignatures struct {
+	mu    sync.Mutex
+	seen  map[string]struct{}
+	order []string
+	next  int
+}
+
+func newRecentSignatures(count int) *recentSignatures {
+	return &recentSignatures{
+		seen:  make(map[string]struct{}, count),
+		order: make([]string, count),
+	}
+}
+
+// add remembers the signature and reports whether it is new, forgetting the oldest one if full.
+func (r *recentSignatures) add(signature string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.seen[signature]; ok {
+		return false
+	}
+	if oldest := r.order[r.next]; oldest != "" {
+		delete(r.seen, oldest)
+	}
+	r.order[r.next] = signature
+	r.next = (r.next + 1) % len(r.order)
+	r.seen[signature] = struct{}{}
+	return true
+}
+
+// remove forgets the signature. Signatures are removed right after they were added, so the
+// search starts from the newest.
+func (r *recentSignatures) remove(signature string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.seen[signature]; !ok {
+		return
+	}
+	delete(r.seen, signature)
+	for i := 1; i <= len(r.order); i++ {
+		index := (r.next - i + len(r.order)) % len(r.order)
+		if r.order[index] == signature {
+			r.order[index] = ""
+			return
+		}
+	}
+}
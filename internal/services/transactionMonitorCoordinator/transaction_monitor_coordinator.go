@@ -2,61 +2,317 @@ package transactionMonitorCoordinator
 
 import (
 	"context"
+	"fmt"
 	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
 	log "github.com/delaram-gholampoor-sagha/SOLSniffer/internal/logger"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/request"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/balanceMonitor"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/gapDetector"
 	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/transactionMonitor"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/watchlist"
 	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/transport/webSocket"
+	"sync"
+	"time"
 )
 
 type Service struct {
 	webSocketManager *webSocket.Manager
 	service          *transactionMonitor.Service
+	balanceMonitor   *balanceMonitor.Service
+	gapDetector      *gapDetector.Service
+	watchlist        *watchlist.Service
+
+	// sem bounds the messages processed at once, across all subscriptions. Messages are
+	// processed concurrently so their transaction fetches can share batch requests.
+	sem chan struct{}
+
+	mu               sync.Mutex
+	slotSubscription *webSocket.Subscription
+	// logSubscriptions and accountSubscriptions hold the logs and account subscription of each
+	// watched address by address
+	logSubscriptions     map[string]*webSocket.Subscription
+	accountSubscriptions map[string]*webSocket.Subscription
+	watching             bool
 }
 
-func New(service *transactionMonitor.Service, webSocketManager *webSocket.Manager) *Service {
+func New(service *transactionMonitor.Service, balanceMonitor *balanceMonitor.Service, gapDetector *gapDetector.Service, watchlist *watchlist.Service, webSocketManager *webSocket.Manager, maxConcurrency int64) *Service {
 	return &Service{
-		webSocketManager: webSocketManager,
-		service:          service,
+		webSocketManager:     webSocketManager,
+		service:              service,
+		balanceMonitor:       balanceMonitor,
+		gapDetector:          gapDetector,
+		watchlist:            watchlist,
+		sem:                  make(chan struct{}, maxConcurrency),
+		logSubscriptions:     make(map[string]*webSocket.Subscription),
+		accountSubscriptions: make(map[string]*webSocket.Subscription),
 	}
 }
 
+// Start subscribes to the logs mentioning each watched wallet or one of its token accounts, so
+// only relevant transactions are streamed, and to the accounts themselves for their balances,
+// and keeps the subscriptions in line with the watchlist. Subscriptions made before a failed attempt are kept, so a retry picks up the rest.
+// Reconnects are handled by the WebSocket manager, which replays the subscriptions; the
+// coordinator only accounts for the outage.
 func (c *Service) Start(ctx context.Context) error {
 	log.Infof("Starting transaction monitor coordinator...")
 
-	subscriptionID, err := c.webSocketManager.Subscribe(ctx, enums.LogsSubscribe)
-	if err != nil {
+	if err := c.subscribe(ctx); err != nil {
 		return err
 	}
-	log.Infof("Subscribed to logs with subscription ID: %s", subscriptionID)
-
-	// Run message listening in a goroutine
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				log.Infof("Transaction monitor coordinator stopped")
-				_ = c.webSocketManager.Unsubscribe(ctx, enums.LogsUnsubscribe)
-				return
-			default:
-				message, err := c.webSocketManager.ReadMessage()
-				if err != nil {
-					log.Errorf("Error reading WebSocket message")
-					continue
-				}
+
+	c.mu.Lock()
+	if !c.watching {
+		c.watching = true
+		c.watchlist.OnChange(c.updateSubscriptions)
+		c.webSocketManager.OnReconnect(func(event webSocket.Reconnect) {
+			c.resume(ctx, event)
+		})
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// subscribe opens the slot subscription and the logs subscriptions that are missing.
+func (c *Service) subscribe(ctx context.Context) error {
+	c.mu.Lock()
+	subscribed := c.slotSubscription != nil
+	c.mu.Unlock()
+	if !subscribed {
+		// Slot updates reveal the slots the stream missed
+		subscription, err := c.webSocketManager.Subscribe(ctx, enums.SlotSubscribe)
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		c.slotSubscription = subscription
+		c.mu.Unlock()
+		go c.consumeSlots(ctx, subscription)
+		log.Infof("Subscribed to slots with subscription ID: %s", subscription.ID())
+	}
+
+	for _, wallet := range c.watchlist.Wallets() {
+		if err := c.watchWallet(ctx, wallet.Address); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resume runs after the WebSocket manager reconnected. Notifications sent during the outage are
+// lost, so it is handed to the gap detector and the balances are read again, and subscriptions
+// the node refused to replay are opened again.
+func (c *Service) resume(ctx context.Context, event webSocket.Reconnect) {
+	log.Warnf("Live stream was down from %s to %s", event.DisconnectedAt.Format(time.RFC3339), event.ReconnectedAt.Format(time.RFC3339))
+	c.gapDetector.StreamResumed(ctx, event.DisconnectedAt, event.ReconnectedAt)
+
+	if err := c.subscribe(ctx); err != nil {
+		log.Errorf("Failed to restore subscriptions after reconnect: %v", err)
+	}
+
+	for _, wallet := range c.watchlist.Wallets() {
+		for _, address := range c.watchlist.Addresses(wallet.Address) {
+			if err := c.balanceMonitor.Refresh(ctx, address); err != nil {
+				log.Warnf("Failed to refresh balance of %s: %v", address, err)
+			}
+		}
+	}
+}
+
+// consumeLogs processes the transactions of a logs subscription until it ends.
+func (c *Service) consumeLogs(ctx context.Context, subscription *webSocket.Subscription) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-subscription.Done():
+			c.forget(subscription)
+			return
+		case message := <-subscription.Notifications():
+			c.sem <- struct{}{} // Acquire a semaphore slot
+			go func(message []byte) {
+				defer func() { <-c.sem }() // Release the semaphore slot
 				if err := c.service.ProcessMessage(ctx, message); err != nil {
 					log.Errorf("Failed to process WebSocket message")
 				}
+			}(message)
+		}
+	}
+}
+
+// consumeAccounts records the balances of an account subscription in stream order until it ends.
+func (c *Service) consumeAccounts(ctx context.Context, address string, subscription *webSocket.Subscription) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-subscription.Done():
+			c.forget(subscription)
+			return
+		case message := <-subscription.Notifications():
+			if err := c.balanceMonitor.ProcessAccountUpdate(ctx, address, message); err != nil {
+				log.Errorf("Failed to process account update of %s: %v", address, err)
+			}
+		}
+	}
+}
+
+// consumeSlots hands slot updates to the gap detector in stream order.
+func (c *Service) consumeSlots(ctx context.Context, subscription *webSocket.Subscription) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-subscription.Done():
+			c.forget(subscription)
+			return
+		case message := <-subscription.Notifications():
+			update, err := request.ParseSlotUpdate(message)
+			if err != nil {
+				log.Errorf("Failed to process slot update: %v", err)
+				continue
+			}
+			c.gapDetector.ObserveSlot(ctx, update.Params.Result.Slot, update.Params.Result.Parent)
+		}
+	}
+}
+
+// forget drops an ended subscription, so the next resume opens it again. Subscriptions ended by
+// the coordinator itself are already gone.
+func (c *Service) forget(subscription *webSocket.Subscription) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.slotSubscription == subscription {
+		c.slotSubscription = nil
+	}
+	for _, subscriptions := range []map[string]*webSocket.Subscription{c.logSubscriptions, c.accountSubscriptions} {
+		for address, addressSubscription := range subscriptions {
+			if addressSubscription == subscription {
+				delete(subscriptions, address)
+			}
+		}
+	}
+}
+
+// updateSubscriptions follows watchlist changes.
+func (c *Service) updateSubscriptions(ctx context.Context, added, removed []string) {
+	for _, wallet := range added {
+		if err := c.watchWallet(ctx, wallet); err != nil {
+			log.Errorf("Failed to watch wallet %s: %v", wallet, err)
+		}
+	}
+	for _, wallet := range removed {
+		c.unwatchWallet(ctx, wallet)
+	}
+}
+
+// watchWallet subscribes to the logs and the account of the wallet and its token accounts where
+// not subscribed yet. The balance of a newly subscribed account is read right away, as the
+// subscription only reports changes.
+func (c *Service) watchWallet(ctx context.Context, wallet string) error {
+	for _, address := range c.watchlist.Addresses(wallet) {
+		c.mu.Lock()
+		_, logsSubscribed := c.logSubscriptions[address]
+		_, accountSubscribed := c.accountSubscriptions[address]
+		c.mu.Unlock()
+
+		if !logsSubscribed {
+			subscription, err := c.webSocketManager.Subscribe(ctx, enums.LogsSubscribe, map[string]interface{}{
+				"mentions": []string{address},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to subscribe to logs of %s: %w", address, err)
+			}
+
+			c.mu.Lock()
+			c.logSubscriptions[address] = subscription
+			c.mu.Unlock()
+			go c.consumeLogs(ctx, subscription)
+			log.Debugf("Subscribed to logs of %s with subscription ID: %s", address, subscription.ID())
+		}
+
+		if !accountSubscribed {
+			subscription, err := c.webSocketManager.Subscribe(ctx, enums.AccountSubscribe, address, map[string]interface{}{
+				"encoding": "base64",
+			})
+			if err != nil {
+				return fmt.Errorf("failed to subscribe to account %s: %w", address, err)
+			}
+
+			c.mu.Lock()
+			c.accountSubscriptions[address] = subscription
+			c.mu.Unlock()
+			go c.consumeAccounts(ctx, address, subscription)
+			log.Debugf("Subscribed to account %s with subscription ID: %s", address, subscription.ID())
+
+			if err := c.balanceMonitor.Refresh(ctx, address); err != nil {
+				log.Warnf("Failed to read balance of %s: %v", address, err)
 			}
 		}
-	}()
+	}
+	log.Infof("Watching wallet %s", wallet)
 	return nil
 }
 
+func (c *Service) unwatchWallet(ctx context.Context, wallet string) {
+	for _, address := range c.watchlist.Addresses(wallet) {
+		c.mu.Lock()
+		logSubscription, logsSubscribed := c.logSubscriptions[address]
+		accountSubscription, accountSubscribed := c.accountSubscriptions[address]
+		delete(c.logSubscriptions, address)
+		delete(c.accountSubscriptions, address)
+		c.mu.Unlock()
+
+		if logsSubscribed {
+			if err := c.webSocketManager.Unsubscribe(ctx, logSubscription); err != nil {
+				log.Errorf("Failed to unsubscribe from logs of %s: %v", address, err)
+			}
+		}
+		if accountSubscribed {
+			if err := c.webSocketManager.Unsubscribe(ctx, accountSubscription); err != nil {
+				log.Errorf("Failed to unsubscribe from account %s: %v", address, err)
+			}
+		}
+		c.balanceMonitor.Forget(address)
+	}
+	log.Infof("Stopped watching wallet %s", wallet)
+}
+
+// unsubscribeAll ends every subscription and returns the first error.
+func (c *Service) unsubscribeAll(ctx context.Context) error {
+	c.mu.Lock()
+	logSubscriptions := c.logSubscriptions
+	accountSubscriptions := c.accountSubscriptions
+	slotSubscription := c.slotSubscription
+	c.logSubscriptions = make(map[string]*webSocket.Subscription)
+	c.accountSubscriptions = make(map[string]*webSocket.Subscription)
+	c.slotSubscription = nil
+	c.mu.Unlock()
+
+	var firstErr error
+	for address, subscription := range logSubscriptions {
+		if err := c.webSocketManager.Unsubscribe(ctx, subscription); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to unsubscribe from logs of %s: %w", address, err)
+		}
+	}
+	for address, subscription := range accountSubscriptions {
+		if err := c.webSocketManager.Unsubscribe(ctx, subscription); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to unsubscribe from account %s: %w", address, err)
+		}
+	}
+	if slotSubscription != nil {
+		if err := c.webSocketManager.Unsubscribe(ctx, slotSubscription); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to unsubscribe from slots: %w", err)
+		}
+	}
+	return firstErr
+}
+
 func (c *Service) Stop(ctx context.Context) error {
 	log.Infof("Stopping transaction monitor coordinator...")
 
-	if err := c.webSocketManager.Unsubscribe(ctx, enums.LogsUnsubscribe); err != nil {
-		log.Errorf("Failed to unsubscribe from logs")
+	if err := c.unsubscribeAll(ctx); err != nil {
+		log.Errorf("Failed to unsubscribe from the stream")
 		return err
 	}
 
@@ -0,0 +1,199 @@
+package watchlist
+
+import (
+	"context"
+	"fmt"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/configs"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/services/instructionDecoder"
+	"sort"
+	"sync"
+)
+
+// Listener is told which wallets were added to and removed from the watchlist.
+type Listener func(ctx context.Context, added, removed []string)
+
+// Service is the set of monitored wallets. Along with each wallet it tracks the associated token
+// accounts for the monitored mints, under both token programs, since token transfers only
+// reference the token accounts and not the wallet that owns them.
+type Service struct {
+	mints []string
+
+	mu        sync.RWMutex
+	wallets   map[string]enums.Direction
+	accounts  map[string]tokenAccount
+	listeners []Listener
+}
+
+type tokenAccount struct {
+	owner string
+	mint  string
+}
+
+func New(wallets []configs.WalletConfig, mints []string) *Service {
+	s := &Service{
+		mints:    mints,
+		wallets:  make(map[string]enums.Direction),
+		accounts: make(map[string]tokenAccount),
+	}
+	for _, wallet := range wallets {
+		s.add(wallet)
+	}
+	return s
+}
+
+// OnChange registers a listener for later changes.
+func (s *Service) OnChange(listener Listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// Add starts monitoring a wallet, or changes the direction monitored for it.
+func (s *Service) Add(ctx context.Context, wallet configs.WalletConfig) error {
+	if common.PublicKeyFromString(wallet.Address) == (common.PublicKey{}) {
+		return fmt.Errorf("invalid wallet address %q", wallet.Address)
+	}
+	if wallet.Direction == "" {
+		wallet.Direction = enums.DirectionBoth
+	}
+	if !wallet.Direction.IsValid() {
+		return fmt.Errorf("invalid direction %q for wallet %s", wallet.Direction, wallet.Address)
+	}
+
+	s.mu.Lock()
+	_, existed := s.wallets[wallet.Address]
+	s.add(wallet)
+	listeners := s.listeners
+	s.mu.Unlock()
+
+	if !existed {
+		for _, listener := range listeners {
+			listener(ctx, []string{wallet.Address}, nil)
+		}
+	}
+	return nil
+}
+
+// Remove stops monitoring a wallet.
+func (s *Service) Remove(ctx context.Context, address string) error {
+	s.mu.Lock()
+	if _, ok := s.wallets[address]; !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("wallet %s is not monitored", address)
+	}
+	delete(s.wallets, address)
+	for account := range s.tokenAccounts(address) {
+		delete(s.accounts, account)
+	}
+	listeners := s.listeners
+	s.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(ctx, nil, []string{address})
+	}
+	return nil
+}
+
+// add records the wallet and its token accounts. The caller holds s.mu or owns s.
+func (s *Service) add(wallet configs.WalletConfig) {
+	s.wallets[wallet.Address] = wallet.Direction
+	for account, mint := range s.tokenAccounts(wallet.Address) {
+		s.accounts[account] = tokenAccount{owner: wallet.Address, mint: mint}
+	}
+}
+
+// Wallets returns the monitored wallets ordered by address.
+func (s *Service) Wallets() []configs.WalletConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	wallets := make([]configs.WalletConfig, 0, len(s.wallets))
+	for address, direction := range s.wallets {
+		wallets = append(wallets, configs.WalletConfig{Address: address, Direction: direction})
+	}
+	sort.Slice(wallets, func(i, j int) bool { return wallets[i].Address < wallets[j].Address })
+	return wallets
+}
+
+// Direction returns the direction monitored for the wallet, if it is monitored.
+func (s *Service) Direction(address string) (enums.Direction, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	direction, ok := s.wallets[address]
+	return direction, ok
+}
+
+// OwnerOf returns the monitored wallet an associated token account belongs to.
+func (s *Service) OwnerOf(account string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tokenAccount, ok := s.accounts[account]
+	return tokenAccount.owner, ok
+}
+
+// MintOf returns the mint of a monitored wallet's associated token account.
+func (s *Service) MintOf(account string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tokenAccount, ok := s.accounts[account]
+	return tokenAccount.mint, ok
+}
+
+// Addresses returns the wallet followed by its associated token accounts for the monitored
+// mints. The accounts do not need to exist yet.
+func (s *Service) Addresses(wallet string) []string {
+	addresses := []string{wallet}
+	for _, mint := range s.mints {
+		addresses = append(addresses, mintAccounts(wallet, mint)...)
+	}
+	return addresses
+}
+
+// tokenAccounts maps the wallet's associated token accounts to their mint.
+func (s *Service) tokenAccounts(wallet string) map[string]string {
+	accounts := make(map[string]string)
+	for _, mint := range s.mints {
+		for _, account := range mintAccounts(wallet, mint) {
+			accounts[account] = mint
+		}
+	}
+	return accounts
+}
+
+// mintAccounts returns the wallet's associated token accounts for a mint under both token
+// programs. Native SOL is held by the wallet itself.
+func mintAccounts(wallet, mint string) []string {
+	if mint == instructionDecoder.NativeSOLMint {
+		return nil
+	}
+	var accounts []string
+	for _, programID := range []common.PublicKey{common.TokenProgramID, common.Token2022ProgramID} {
+		account, err := DeriveAssociatedTokenAddress(wallet, mint, programID)
+		if err != nil {
+			continue
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts
+}
+
+// DeriveAssociatedTokenAddress derives the associated token account of a wallet for a mint under
+// the given token program.
+func DeriveAssociatedTokenAddress(wallet, mint string, programID common.PublicKey) (string, error) {
+	walletKey := common.PublicKeyFromString(wallet)
+	mintKey := common.PublicKeyFromString(mint)
+	if walletKey == (common.PublicKey{}) || mintKey == (common.PublicKey{}) {
+		return "", fmt.Errorf("invalid wallet %s or mint %s", wallet, mint)
+	}
+
+	ata, _, err := common.FindProgramAddress(
+		[][]byte{walletKey.Bytes(), programID.Bytes(), mintKey.Bytes()},
+		common.SPLAssociatedTokenAccountProgramID,
+	)
+	if err != nil {
+		return "", err
+	}
+	return ata.ToBase58(), nil
+}
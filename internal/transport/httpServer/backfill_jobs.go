@@ -0,0 +1,204 @@
+package httpServer
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/contracts/services"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
+	log "github.com/delaram-gholampoor-sagha/SOLSniffer/internal/logger"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/request"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const backfillJobsPath = "/backfill/jobs"
+
+// backfillJobHandler manages backfill jobs:
+//
+//	GET  /backfill/jobs                lists every job, oldest first
+//	POST /backfill/jobs                creates a job from a request.CreateBackfillJob body
+//	GET  /backfill/jobs/{id}           returns one job
+//	POST /backfill/jobs/{id}/pause     pauses a queued or running job
+//	POST /backfill/jobs/{id}/resume    queues a paused or failed job again
+//	POST /backfill/jobs/{id}/cancel    stops a job for good
+type backfillJobHandler struct {
+	jobs services.BackfillJobs
+}
+
+// backfillJob is the JSON form of a backfill job.
+type backfillJob struct {
+	ID         string                  `json:"id"`
+	Mode       enums.BackfillMode      `json:"mode"`
+	Status     enums.BackfillJobStatus `json:"status"`
+	Range      *slotRange              `json:"range,omitempty"`
+	From       *time.Time              `json:"from,omitempty"`
+	To         *time.Time              `json:"to,omitempty"`
+	Completed  []slotRange             `json:"completed,omitempty"`
+	Done       uint64                  `json:"done"`
+	Total      uint64                  `json:"total"`
+	Errors     int64                   `json:"errors"`
+	LastError  string                  `json:"last_error,omitempty"`
+	CreatedAt  time.Time               `json:"created_at"`
+	StartedAt  *time.Time              `json:"started_at,omitempty"`
+	FinishedAt *time.Time              `json:"finished_at,omitempty"`
+	UpdatedAt  time.Time               `json:"updated_at"`
+}
+
+type slotRange struct {
+	Start uint64 `json:"start"`
+	End   uint64 `json:"end"`
+}
+
+func newBackfillJob(job entity.BackfillJob) backfillJob {
+	view := backfillJob{
+		ID:         job.ID.Hex(),
+		Mode:       job.Mode,
+		Status:     job.Status,
+		From:       job.From,
+		To:         job.To,
+		Done:       job.Progress.Done,
+		Total:      job.Progress.Total,
+		Errors:     job.Errors,
+		LastError:  job.LastError,
+		CreatedAt:  job.CreatedAt,
+		StartedAt:  job.StartedAt,
+		FinishedAt: job.FinishedAt,
+		UpdatedAt:  job.UpdatedAt,
+	}
+	if job.Range != nil {
+		view.Range = &slotRange{Start: job.Range.Start, End: job.Range.End}
+	}
+	for _, completed := range job.Completed {
+		view.Completed = append(view.Completed, slotRange{Start: completed.Start, End: completed.End})
+	}
+	return view
+}
+
+func (h *backfillJobHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, backfillJobsPath), "/")
+	if path == "" {
+		switch r.Method {
+		case http.MethodGet:
+			h.list(w, r)
+		case http.MethodPost:
+			h.create(w, r)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodPost)
+		}
+		return
+	}
+
+	id, action, _ := strings.Cut(path, "/")
+	jobID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "invalid backfill job id "+id)
+		return
+	}
+
+	switch action {
+	case "":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		h.get(w, r, jobID)
+	case "pause", "resume", "cancel":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		h.transition(w, r, jobID, action)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *backfillJobHandler) list(w http.ResponseWriter, r *http.Request) {
+	jobs, err := h.jobs.List(r.Context())
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	views := make([]backfillJob, 0, len(jobs))
+	for _, job := range jobs {
+		views = append(views, newBackfillJob(job))
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+func (h *backfillJobHandler) create(w http.ResponseWriter, r *http.Request) {
+	create, err := request.ParseCreateBackfillJob(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	job := create.Job()
+	if err := h.jobs.Create(r.Context(), job); err != nil {
+		h.fail(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, newBackfillJob(*job))
+}
+
+func (h *backfillJobHandler) get(w http.ResponseWriter, r *http.Request, id primitive.ObjectID) {
+	job, err := h.jobs.Get(r.Context(), id)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newBackfillJob(*job))
+}
+
+func (h *backfillJobHandler) transition(w http.ResponseWriter, r *http.Request, id primitive.ObjectID, action string) {
+	var err error
+	switch action {
+	case "pause":
+		err = h.jobs.Pause(r.Context(), id)
+	case "resume":
+		err = h.jobs.Resume(r.Context(), id)
+	case "cancel":
+		err = h.jobs.Cancel(r.Context(), id)
+	}
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	h.get(w, r, id)
+}
+
+// fail answers with the status the error calls for.
+func (h *backfillJobHandler) fail(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidBackfillJob):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrBackfillJobNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrBackfillJobStatus):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		log.Errorf("Backfill job request failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Warnf("Failed to write HTTP response: %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package httpServer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/contracts/services"
+	log "github.com/delaram-gholampoor-sagha/SOLSniffer/internal/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net"
+	"net/http"
+	"time"
+)
+
+// readHeaderTimeout bounds how long a client may take to send its request headers.
+const readHeaderTimeout = 10 * time.Second
+
+// Server is the operator endpoint of the application. It serves the Prometheus metrics on
+// /metrics and manages backfill jobs under /backfill/jobs.
+type Server struct {
+	server *http.Server
+}
+
+func New(addr string, metrics prometheus.Gatherer, jobs services.BackfillJobs) *Server {
+	jobHandler := &backfillJobHandler{jobs: jobs}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(metrics, promhttp.HandlerOpts{}))
+	mux.Handle(backfillJobsPath, jobHandler)
+	mux.Handle(backfillJobsPath+"/", jobHandler)
+
+	return &Server{
+		server: &http.Server{
+			Addr:              addr,
+			Handler:           mux,
+			ReadHeaderTimeout: readHeaderTimeout,
+		},
+	}
+}
+
+// Start listens on the server's address and serves in the background. The listener is opened
+// before it returns, so an address in use fails the start.
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.server.Addr, err)
+	}
+
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("HTTP server stopped: %v", err)
+		}
+	}()
+	log.Infof("HTTP server listening on %s", listener.Addr())
+	return nil
+}
+
+// Shutdown stops accepting requests and waits for the ones in flight until ctx is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
@@ -0,0 +1,387 @@
+package solanaClient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/contracts/services"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
+	log "github.com/delaram-gholampoor-sagha/SOLSniffer/internal/logger"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// healthSmoothing is the weight of the newest call in the latency and error rate averages.
+	healthSmoothing = 0.2
+	// errorRatePenalty multiplies an endpoint's latency by 1 + errorRatePenalty * errorRate.
+	errorRatePenalty = 10
+	// slotLagPenalty is the latency, in milliseconds, one slot of lag is worth.
+	slotLagPenalty = 10
+
+	failureCooldown   = 2 * time.Second
+	rateLimitCooldown = 5 * time.Second
+)
+
+// Endpoint is one JSON-RPC provider. Name labels its metrics and logs, so it should not contain
+// API keys.
+type Endpoint struct {
+	Name      string
+	URL       string
+	Headers   map[string]string
+	RateLimit RateLimit
+}
+
+type endpoint struct {
+	name       string
+	url        string
+	httpClient *http.Client
+	client     *client.Client
+	limiter    *rateLimiter
+	// batchRejected is set once the endpoint answered a batch request with a single error
+	batchRejected atomic.Bool
+
+	mu            sync.Mutex
+	latency       time.Duration
+	errorRate     float64
+	slot          uint64
+	slotLag       uint64
+	cooldownUntil time.Time
+}
+
+// score ranks endpoints for routing; lower is healthier. The caller holds e.mu.
+func (e *endpoint) score() float64 {
+	latency := float64(e.latency) / float64(time.Millisecond)
+	return latency*(1+errorRatePenalty*e.errorRate) + float64(e.slotLag)*slotLagPenalty
+}
+
+func (e *endpoint) health() entity.RPCEndpointHealth {
+	return entity.RPCEndpointHealth{
+		Endpoint:  e.name,
+		Score:     e.score(),
+		Latency:   e.latency,
+		ErrorRate: e.errorRate,
+		SlotLag:   e.slotLag,
+	}
+}
+
+// endpointPool routes each call to the healthiest endpoint and fails over to the next one when it
+// errors or is rate limited.
+type endpointPool struct {
+	endpoints []*endpoint
+	monitor   services.Monitoring
+}
+
+func newEndpointPool(endpoints []Endpoint, timeout time.Duration, monitor services.Monitoring) *endpointPool {
+	pool := &endpointPool{monitor: monitor}
+	for _, e := range endpoints {
+		httpClient := &http.Client{
+			Timeout:   timeout,
+			Transport: &endpointTransport{headers: e.Headers, base: http.DefaultTransport},
+		}
+		pool.endpoints = append(pool.endpoints, &endpoint{
+			name:       e.Name,
+			url:        e.URL,
+			httpClient: httpClient,
+			client:     client.New(rpc.WithEndpoint(e.URL), rpc.WithHTTPClient(httpClient)),
+			limiter:    newRateLimiter(e.RateLimit),
+		})
+	}
+	return pool
+}
+
+// ranked orders the endpoints by score. Endpoints cooling down after a failure come last, so they
+// are only tried once every other endpoint has failed. Ties keep the configured order.
+func (p *endpointPool) ranked() []*endpoint {
+	type candidate struct {
+		endpoint      *endpoint
+		score         float64
+		cooldownUntil time.Time
+	}
+
+	now := time.Now()
+	candidates := make([]candidate, 0, len(p.endpoints))
+	for _, e := range p.endpoints {
+		e.mu.Lock()
+		candidates = append(candidates, candidate{endpoint: e, score: e.score(), cooldownUntil: e.cooldownUntil})
+		e.mu.Unlock()
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		iCooling, jCooling := now.Before(candidates[i].cooldownUntil), now.Before(candidates[j].cooldownUntil)
+		if iCooling != jCooling {
+			return jCooling
+		}
+		if iCooling {
+			return candidates[i].cooldownUntil.Before(candidates[j].cooldownUntil)
+		}
+		return candidates[i].score < candidates[j].score
+	})
+
+	ranked := make([]*endpoint, len(candidates))
+	for i, c := range candidates {
+		ranked[i] = c.endpoint
+	}
+	return ranked
+}
+
+// observe folds the outcome of a call into the endpoint's health and records it.
+func (p *endpointPool) observe(e *endpoint, method string, outcome enums.RPCOutcome, elapsed, retryAfter time.Duration) {
+	failed := 0.0
+	if outcome == enums.RPCFailed || outcome == enums.RPCRateLimited {
+		failed = 1
+	}
+
+	e.mu.Lock()
+	if e.latency == 0 {
+		e.latency = elapsed
+	} else {
+		e.latency += time.Duration(healthSmoothing * float64(elapsed-e.latency))
+	}
+	e.errorRate += healthSmoothing * (failed - e.errorRate)
+	switch outcome {
+	case enums.RPCRateLimited:
+		if retryAfter <= 0 {
+			retryAfter = rateLimitCooldown
+		}
+		e.cooldownUntil = time.Now().Add(retryAfter)
+	case enums.RPCFailed:
+		e.cooldownUntil = time.Now().Add(failureCooldown)
+	}
+	health := e.health()
+	cooldownUntil := e.cooldownUntil
+	e.mu.Unlock()
+
+	// The provider asked us to back off, so hold every call to it rather than only deprioritizing it.
+	if outcome == enums.RPCRateLimited {
+		e.limiter.pause(cooldownUntil)
+	}
+
+	p.record(entity.NewEvent(entity.RPCRequestEvent, entity.RPCRequest{
+		Endpoint: e.name,
+		Method:   method,
+		Outcome:  outcome,
+		Duration: elapsed,
+	}))
+	p.record(entity.NewEvent(entity.RPCEndpointHealthEvent, health))
+}
+
+// refreshSlots polls every endpoint's slot and measures how far each one lags the most advanced.
+// The polls bypass the rate limiters, so they neither delay live calls nor wait behind backfill.
+func (p *endpointPool) refreshSlots(ctx context.Context, commitment rpc.Commitment) {
+	ctx = WithTraffic(ctx, enums.RPCTrafficHealthCheck)
+	var wg sync.WaitGroup
+	for _, e := range p.endpoints {
+		wg.Add(1)
+		go func(e *endpoint) {
+			defer wg.Done()
+			slot, err := callEndpoint(ctx, p, e, "getSlot", 1, func(ctx context.Context, e *endpoint) (uint64, error) {
+				return e.client.GetSlotWithConfig(ctx, client.GetSlotConfig{Commitment: commitment})
+			})
+			if err != nil {
+				log.Warnf("Failed to fetch slot from RPC endpoint %s: %v", e.name, err)
+				return
+			}
+			e.mu.Lock()
+			e.slot = slot
+			e.mu.Unlock()
+		}(e)
+	}
+	wg.Wait()
+
+	var highest uint64
+	for _, e := range p.endpoints {
+		e.mu.Lock()
+		if e.slot > highest {
+			highest = e.slot
+		}
+		e.mu.Unlock()
+	}
+
+	for _, e := range p.endpoints {
+		e.mu.Lock()
+		if e.slot > 0 {
+			e.slotLag = highest - e.slot
+		}
+		health := e.health()
+		e.mu.Unlock()
+		p.record(entity.NewEvent(entity.RPCEndpointHealthEvent, health))
+	}
+}
+
+func (p *endpointPool) record(event entity.Event) {
+	if p.monitor != nil {
+		p.monitor.Record(event)
+	}
+}
+
+// pick chooses the healthiest candidate that has the budget for the call right away, or the one
+// that will have it soonest when all of them are throttled.
+func (p *endpointPool) pick(candidates []*endpoint, method string, cost int, traffic enums.RPCTraffic) int {
+	best, bestDelay := 0, time.Duration(math.MaxInt64)
+	for i, e := range candidates {
+		delay := e.limiter.delay(method, cost, traffic)
+		if delay == 0 {
+			return i
+		}
+		if delay < bestDelay {
+			best, bestDelay = i, delay
+		}
+	}
+	return best
+}
+
+// call runs fn against the healthiest endpoint with budget left, failing over on transport errors,
+// 5xx and 429 responses. Errors returned by the node itself are passed to the caller as is since
+// any other endpoint would answer the same.
+func call[T any](ctx context.Context, p *endpointPool, method string, fn func(ctx context.Context, c *client.Client) (T, error)) (T, error) {
+	return callWeighted(ctx, p, method, 1, func(ctx context.Context, e *endpoint) (T, error) {
+		return fn(ctx, e.client)
+	})
+}
+
+// callWeighted is call for requests that cost more than one request of budget, such as batches,
+// or that need the endpoint itself rather than its SDK client.
+func callWeighted[T any](ctx context.Context, p *endpointPool, method string, cost int, fn func(ctx context.Context, e *endpoint) (T, error)) (T, error) {
+	var zero T
+	var errs []error
+	traffic := trafficFrom(ctx)
+	for candidates := p.ranked(); len(candidates) > 0; {
+		i := p.pick(candidates, method, cost, traffic)
+		e := candidates[i]
+		candidates = append(candidates[:i:i], candidates[i+1:]...)
+
+		result, err := callEndpoint(ctx, p, e, method, cost, fn)
+		if err == nil || !canFailOver(err) {
+			return result, err
+		}
+		if ctx.Err() != nil {
+			return zero, err
+		}
+		errs = append(errs, err)
+		log.Warnf("RPC %s failed on endpoint %s; failing over: %v", method, e.name, err)
+	}
+	return zero, fmt.Errorf("failed to call %s on every RPC endpoint: %w", method, errors.Join(errs...))
+}
+
+// endpointError is an error an endpoint is to blame for.
+type endpointError struct {
+	endpoint string
+	outcome  enums.RPCOutcome
+	err      error
+}
+
+func (e *endpointError) Error() string {
+	return fmt.Sprintf("%s (%s): %v", e.endpoint, e.outcome, e.err)
+}
+
+func (e *endpointError) Unwrap() error {
+	return e.err
+}
+
+func canFailOver(err error) bool {
+	var endpointErr *endpointError
+	return errors.As(err, &endpointErr)
+}
+
+// callEndpoint waits for the endpoint's rate limiter, runs fn against it and records the outcome.
+// Health checks skip the rate limiter.
+func callEndpoint[T any](ctx context.Context, p *endpointPool, e *endpoint, method string, cost int, fn func(ctx context.Context, e *endpoint) (T, error)) (T, error) {
+	if traffic := trafficFrom(ctx); traffic != enums.RPCTrafficHealthCheck {
+		if err := e.limiter.wait(ctx, method, cost, traffic); err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+
+	status := &responseStatus{}
+	start := time.Now()
+	result, err := fn(context.WithValue(ctx, responseStatusKey{}, status), e)
+	elapsed := time.Since(start)
+
+	// A cancelled caller says nothing about the endpoint.
+	if ctx.Err() != nil {
+		return result, err
+	}
+
+	outcome := classifyOutcome(err, status)
+	p.observe(e, method, outcome, elapsed, status.retryAfter)
+	if outcome == enums.RPCFailed || outcome == enums.RPCRateLimited {
+		return result, &endpointError{endpoint: e.name, outcome: outcome, err: err}
+	}
+	return result, err
+}
+
+func classifyOutcome(err error, status *responseStatus) enums.RPCOutcome {
+	if status.code == http.StatusTooManyRequests {
+		return enums.RPCRateLimited
+	}
+	if err == nil {
+		return enums.RPCSuccess
+	}
+
+	var rpcErr *rpc.JsonRpcError
+	if errors.As(err, &rpcErr) {
+		if rpcErr.Code == http.StatusTooManyRequests {
+			return enums.RPCRateLimited
+		}
+		return enums.RPCError
+	}
+	return enums.RPCFailed
+}
+
+type responseStatusKey struct{}
+
+// responseStatus captures what the SDK does not expose from the HTTP response of a call.
+type responseStatus struct {
+	code       int
+	retryAfter time.Duration
+}
+
+// endpointTransport adds the endpoint's headers, such as provider API keys, to every request and
+// captures the response status into the responseStatus of the request context.
+type endpointTransport struct {
+	headers map[string]string
+	base    http.RoundTripper
+}
+
+func (t *endpointTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(t.headers) > 0 {
+		req = req.Clone(req.Context())
+		for key, value := range t.headers {
+			req.Header.Set(key, value)
+		}
+	}
+
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+	if status, ok := req.Context().Value(responseStatusKey{}).(*responseStatus); ok {
+		status.code = res.StatusCode
+		status.retryAfter = parseRetryAfter(res.Header.Get("Retry-After"))
+	}
+	return res, nil
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
@@ -0,0 +1,176 @@
+package solanaClient
+
+import (
+	"context"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimit is the request budget of one endpoint. RequestsPerSecond caps all calls and Methods
+// caps individual methods such as getBlock; zero means unlimited. Each budget is split between
+// live and backfill traffic by BackfillShare, so backfill can use at most its share and never
+// the tokens reserved for live processing. The pool's health checks are not budgeted.
+type RateLimit struct {
+	RequestsPerSecond float64
+	Methods           map[string]float64
+	BackfillShare     float64
+}
+
+type trafficKey struct{}
+
+// WithTraffic marks the calls made with the returned context as belonging to the given traffic
+// class. Calls default to live traffic.
+func WithTraffic(ctx context.Context, traffic enums.RPCTraffic) context.Context {
+	return context.WithValue(ctx, trafficKey{}, traffic)
+}
+
+func trafficFrom(ctx context.Context) enums.RPCTraffic {
+	if traffic, ok := ctx.Value(trafficKey{}).(enums.RPCTraffic); ok {
+		return traffic
+	}
+	return enums.RPCTrafficLive
+}
+
+// bucketKey identifies a token bucket. An empty method is the endpoint-wide bucket.
+type bucketKey struct {
+	method  string
+	traffic enums.RPCTraffic
+}
+
+// rateLimiter holds the token buckets of one endpoint.
+type rateLimiter struct {
+	limit RateLimit
+
+	mu          sync.Mutex
+	buckets     map[bucketKey]*tokenBucket
+	pausedUntil time.Time
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	return &rateLimiter{limit: limit, buckets: make(map[bucketKey]*tokenBucket)}
+}
+
+// delay is how long a call costing cost requests would have to wait right now, without reserving
+// anything.
+func (l *rateLimiter) delay(method string, cost int, traffic enums.RPCTraffic) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	wait := l.pausedUntil.Sub(now)
+	for _, bucket := range l.bucketsFor(method, traffic) {
+		wait = maxDuration(wait, bucket.delay(now, cost))
+	}
+	return maxDuration(wait, 0)
+}
+
+// wait reserves cost tokens in every bucket the call draws from and sleeps until they are
+// available.
+func (l *rateLimiter) wait(ctx context.Context, method string, cost int, traffic enums.RPCTraffic) error {
+	l.mu.Lock()
+	now := time.Now()
+	wait := l.pausedUntil.Sub(now)
+	for _, bucket := range l.bucketsFor(method, traffic) {
+		wait = maxDuration(wait, bucket.take(now, cost))
+	}
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// pause holds every call to the endpoint until the given time, e.g. for a 429's Retry-After.
+func (l *rateLimiter) pause(until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// bucketsFor returns the endpoint-wide and method buckets that apply, creating them on first use.
+// The caller holds l.mu.
+func (l *rateLimiter) bucketsFor(method string, traffic enums.RPCTraffic) []*tokenBucket {
+	var buckets []*tokenBucket
+	if bucket := l.bucket(bucketKey{traffic: traffic}, l.limit.RequestsPerSecond); bucket != nil {
+		buckets = append(buckets, bucket)
+	}
+	if bucket := l.bucket(bucketKey{method: method, traffic: traffic}, l.limit.Methods[method]); bucket != nil {
+		buckets = append(buckets, bucket)
+	}
+	return buckets
+}
+
+func (l *rateLimiter) bucket(key bucketKey, requestsPerSecond float64) *tokenBucket {
+	if requestsPerSecond <= 0 {
+		return nil
+	}
+	if bucket, ok := l.buckets[key]; ok {
+		return bucket
+	}
+
+	share := 1 - l.limit.BackfillShare
+	if key.traffic == enums.RPCTrafficBackfill {
+		share = l.limit.BackfillShare
+	}
+	bucket := newTokenBucket(requestsPerSecond * share)
+	l.buckets[key] = bucket
+	return bucket
+}
+
+// tokenBucket refills at rate tokens per second up to a burst of one second's worth. Taking a
+// token may drive the balance negative; the caller then waits for the debt to be refilled.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	burst := math.Max(1, rate)
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+// delay is how long until cost tokens are available. A cost above the burst only waits for a
+// full bucket and leaves the rest as debt for later calls, so a large batch can always start.
+func (b *tokenBucket) delay(now time.Time, cost int) time.Duration {
+	b.refill(now)
+	needed := math.Min(float64(cost), b.burst)
+	if b.tokens >= needed || b.rate <= 0 {
+		return 0
+	}
+	return time.Duration((needed - b.tokens) / b.rate * float64(time.Second))
+}
+
+// take reserves cost tokens and returns how long the caller has to wait for them.
+func (b *tokenBucket) take(now time.Time, cost int) time.Duration {
+	wait := b.delay(now, cost)
+	b.tokens -= float64(cost)
+	return wait
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
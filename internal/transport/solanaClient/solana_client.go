@@ -2,37 +2,213 @@ package solanaClient
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/blocto/solana-go-sdk/client"
 	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/contracts/services"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
+	"time"
 )
 
-// SolanaClient wraps the client.Client and provides methods to interact with Solana.
+// Node error codes for slots that have no block.
+const (
+	skippedSlotCode            = -32007
+	skippedLongTermStorageCode = -32009
+)
+
+// SolanaClient wraps a pool of RPC endpoints and provides methods to interact with Solana.
 type SolanaClient struct {
-	client *client.Client
+	endpoints    *endpointPool
+	transactions *transactionBatcher
+	commitment   rpc.Commitment
 }
 
-func New() *SolanaClient {
-	return &SolanaClient{
-		client: client.NewClient(rpc.MainnetRPCEndpoint),
+// New creates a client that spreads calls across the given endpoints, preferring the healthiest.
+// A zero timeout means requests are only bounded by their context.
+func New(endpoints []Endpoint, timeout time.Duration, batching Batching, monitor services.Monitoring) *SolanaClient {
+	sc := &SolanaClient{
+		endpoints:  newEndpointPool(endpoints, timeout, monitor),
+		commitment: rpc.CommitmentFinalized,
+	}
+	if batching.MaxSize > 1 {
+		sc.transactions = newTransactionBatcher(sc, batching, timeout)
 	}
+	return sc
 }
 
-// GetBlockHeight retrieves the current block height from Solana
-func (sc *SolanaClient) GetBlockHeight(ctx context.Context) (int64, error) {
-	blockDetails, err := sc.client.GetBlock(ctx, uint64(0)) // Use slot 0 to get the latest block
-	if err != nil {
-		return 0, fmt.Errorf("failed to fetch latest block details: %w", err)
+// MonitorEndpoints polls the slot of every endpoint at the given interval so lagging endpoints
+// lose priority. It returns when the context is done.
+func (sc *SolanaClient) MonitorEndpoints(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		sc.endpoints.refreshSlots(ctx, sc.commitment)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
-	return *blockDetails.BlockHeight, nil
 }
 
-// GetBlock retrieves block details by slot
+// ClusterEndpoint returns the public JSON-RPC endpoint of a cluster, defaulting to mainnet.
+func ClusterEndpoint(cluster enums.Cluster) string {
+	switch cluster {
+	case enums.ClusterDevnet:
+		return rpc.DevnetRPCEndpoint
+	case enums.ClusterTestnet:
+		return rpc.TestnetRPCEndpoint
+	case enums.ClusterLocalnet:
+		return rpc.LocalnetRPCEndpoint
+	default:
+		return rpc.MainnetRPCEndpoint
+	}
+}
+
+// Commitment returns the commitment level blocks and transactions are fetched at
+func (sc *SolanaClient) Commitment() rpc.Commitment {
+	return sc.commitment
+}
+
+// GetSlot retrieves the latest slot at the client's commitment
+func (sc *SolanaClient) GetSlot(ctx context.Context) (uint64, error) {
+	return call(ctx, sc.endpoints, "getSlot", func(ctx context.Context, c *client.Client) (uint64, error) {
+		return c.GetSlotWithConfig(ctx, client.GetSlotConfig{Commitment: sc.commitment})
+	})
+}
+
+// GetBlocks lists the slots between start and end, both included, that produced a block. Slots
+// missing from the list were skipped by their leader. The range may span at most 500,000 slots.
+func (sc *SolanaClient) GetBlocks(ctx context.Context, start, end uint64) ([]uint64, error) {
+	return call(ctx, sc.endpoints, "getBlocks", func(ctx context.Context, c *client.Client) ([]uint64, error) {
+		res, err := c.RpcClient.GetBlocksWithConfig(ctx, start, end, rpc.GetBlocksConfig{Commitment: sc.commitment})
+		if err != nil {
+			return nil, err
+		}
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		return res.Result, nil
+	})
+}
+
+// GetBlocksWithLimit lists up to limit slots holding a block, starting at start.
+func (sc *SolanaClient) GetBlocksWithLimit(ctx context.Context, start, limit uint64) ([]uint64, error) {
+	return call(ctx, sc.endpoints, "getBlocksWithLimit", func(ctx context.Context, c *client.Client) ([]uint64, error) {
+		res, err := c.RpcClient.GetBlocksWithLimitWithConfig(ctx, start, limit, rpc.GetBlocksWithLimitConfig{Commitment: sc.commitment})
+		if err != nil {
+			return nil, err
+		}
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		return res.Result, nil
+	})
+}
+
+// GetFirstAvailableBlock returns the lowest slot the node still holds a block for.
+func (sc *SolanaClient) GetFirstAvailableBlock(ctx context.Context) (uint64, error) {
+	return call(ctx, sc.endpoints, "getFirstAvailableBlock", func(ctx context.Context, c *client.Client) (uint64, error) {
+		return c.GetFirstAvailableBlock(ctx)
+	})
+}
+
+// GetBlockTime returns the estimated production time of the block at the slot.
+func (sc *SolanaClient) GetBlockTime(ctx context.Context, slot uint64) (time.Time, error) {
+	return call(ctx, sc.endpoints, "getBlockTime", func(ctx context.Context, c *client.Client) (time.Time, error) {
+		blockTime, err := c.GetBlockTime(ctx, slot)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if blockTime == nil {
+			return time.Time{}, fmt.Errorf("block at slot %d has no block time", slot)
+		}
+		return time.Unix(*blockTime, 0), nil
+	})
+}
+
+// GetBlock retrieves block details by slot. The client requests maxSupportedTransactionVersion 0,
+// so v0 transactions are returned with the addresses they loaded from lookup tables appended to
+// their AccountKeys.
 func (sc *SolanaClient) GetBlock(ctx context.Context, slot uint64) (*client.Block, error) {
-	return sc.client.GetBlock(ctx, slot)
+	return call(ctx, sc.endpoints, "getBlock", func(ctx context.Context, c *client.Client) (*client.Block, error) {
+		return c.GetBlockWithConfig(ctx, slot, client.GetBlockConfig{Commitment: sc.commitment})
+	})
 }
 
-// GetTransaction fetches transaction details by signature
+// GetTransaction fetches transaction details by signature. Like GetBlock it accepts v0
+// transactions and resolves their lookup table addresses into AccountKeys. Concurrent calls are
+// sent together as batch requests when batching is enabled.
 func (sc *SolanaClient) GetTransaction(ctx context.Context, signature string) (*client.Transaction, error) {
-	return sc.client.GetTransaction(ctx, signature)
+	if sc.transactions != nil {
+		return sc.transactions.GetTransaction(ctx, signature)
+	}
+	return call(ctx, sc.endpoints, "getTransaction", func(ctx context.Context, c *client.Client) (*client.Transaction, error) {
+		return c.GetTransactionWithConfig(ctx, signature, client.GetTransactionConfig{Commitment: sc.commitment})
+	})
+}
+
+// GetSignaturesForAddress lists signatures of transactions involving the address, newest first.
+// Before and Until in the config page through the history; both are exclusive.
+func (sc *SolanaClient) GetSignaturesForAddress(ctx context.Context, address string, config client.GetSignaturesForAddressConfig) (rpc.GetSignaturesForAddress, error) {
+	config.Commitment = sc.commitment
+	return call(ctx, sc.endpoints, "getSignaturesForAddress", func(ctx context.Context, c *client.Client) (rpc.GetSignaturesForAddress, error) {
+		return c.GetSignaturesForAddressWithConfig(ctx, address, config)
+	})
+}
+
+// GetTokenAccountsByOwner lists the addresses of the owner's token accounts for a mint, under
+// either token program.
+func (sc *SolanaClient) GetTokenAccountsByOwner(ctx context.Context, owner, mint string) ([]string, error) {
+	return call(ctx, sc.endpoints, "getTokenAccountsByOwner", func(ctx context.Context, c *client.Client) ([]string, error) {
+		// Only the addresses are needed, so skip the account data.
+		res, err := c.RpcClient.GetTokenAccountsByOwnerWithConfig(ctx, owner,
+			rpc.GetTokenAccountsByOwnerConfigFilter{Mint: mint},
+			rpc.GetTokenAccountsByOwnerConfig{
+				Commitment: sc.commitment,
+				Encoding:   rpc.AccountEncodingBase64,
+				DataSlice:  &rpc.DataSlice{},
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if res.Error != nil {
+			return nil, res.Error
+		}
+
+		addresses := make([]string, 0, len(res.Result.Value))
+		for _, account := range res.Result.Value {
+			addresses = append(addresses, account.Pubkey)
+		}
+		return addresses, nil
+	})
+}
+
+// GetAccountInfo fetches the raw account data for an address
+func (sc *SolanaClient) GetAccountInfo(ctx context.Context, address string) (client.AccountInfo, error) {
+	return call(ctx, sc.endpoints, "getAccountInfo", func(ctx context.Context, c *client.Client) (client.AccountInfo, error) {
+		return c.GetAccountInfo(ctx, address)
+	})
+}
+
+// GetAccountInfoAndContext fetches the raw account data for an address along with the slot it was
+// read at. A missing account comes back empty.
+func (sc *SolanaClient) GetAccountInfoAndContext(ctx context.Context, address string) (rpc.ValueWithContext[client.AccountInfo], error) {
+	return call(ctx, sc.endpoints, "getAccountInfo", func(ctx context.Context, c *client.Client) (rpc.ValueWithContext[client.AccountInfo], error) {
+		return c.GetAccountInfoAndContextWithConfig(ctx, address, client.GetAccountInfoConfig{Commitment: sc.commitment})
+	})
+}
+
+// IsSkippedSlot reports whether a GetBlock error means the slot has no block because its leader
+// skipped it, which retrying will not change.
+func IsSkippedSlot(err error) bool {
+	var rpcErr *rpc.JsonRpcError
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	return rpcErr.Code == skippedSlotCode || rpcErr.Code == skippedLongTermStorageCode
 }
@@ -0,0 +1,288 @@
+package solanaClient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
+	log "github.com/delaram-gholampoor-sagha/SOLSniffer/internal/logger"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Batching coalesces concurrent GetTransaction calls into JSON-RPC batch requests. A batch is sent
+// once it holds MaxSize calls or Window after its first call, whichever comes first. A MaxSize
+// below two disables batching.
+type Batching struct {
+	MaxSize int
+	Window  time.Duration
+}
+
+// defaultBatchTimeout bounds a batch when the client has no RPC timeout.
+const defaultBatchTimeout = 30 * time.Second
+
+// errBatchRejected is returned by endpoints that do not accept batch requests.
+var errBatchRejected = errors.New("batch request rejected")
+
+type transactionResult struct {
+	transaction *client.Transaction
+	err         error
+}
+
+type transactionRequest struct {
+	signature string
+	result    chan transactionResult
+}
+
+// transactionBatcher queues GetTransaction calls per traffic class, so batches keep drawing from
+// the budget of the traffic that made them.
+type transactionBatcher struct {
+	sc       *SolanaClient
+	batching Batching
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	pending map[enums.RPCTraffic][]*transactionRequest
+}
+
+func newTransactionBatcher(sc *SolanaClient, batching Batching, timeout time.Duration) *transactionBatcher {
+	if timeout <= 0 {
+		timeout = defaultBatchTimeout
+	}
+	return &transactionBatcher{
+		sc:       sc,
+		batching: batching,
+		timeout:  timeout,
+		pending:  make(map[enums.RPCTraffic][]*transactionRequest),
+	}
+}
+
+// GetTransaction queues the signature and waits for the batch it joins. The batch is sent on a
+// context of its own so one caller giving up does not fail the others; it is bounded by the RPC
+// timeout instead, rate limiter wait and failover included.
+func (b *transactionBatcher) GetTransaction(ctx context.Context, signature string) (*client.Transaction, error) {
+	request := &transactionRequest{signature: signature, result: make(chan transactionResult, 1)}
+	traffic := trafficFrom(ctx)
+
+	b.mu.Lock()
+	queue := append(b.pending[traffic], request)
+	b.pending[traffic] = queue
+	switch {
+	case len(queue) >= b.batching.MaxSize:
+		b.pending[traffic] = nil
+		go b.send(traffic, queue)
+	case len(queue) == 1:
+		time.AfterFunc(b.batching.Window, func() { b.flush(traffic, request) })
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-request.result:
+		return result.transaction, result.err
+	}
+}
+
+// flush sends the queue of the traffic class if it is still the batch started by first.
+func (b *transactionBatcher) flush(traffic enums.RPCTraffic, first *transactionRequest) {
+	b.mu.Lock()
+	queue := b.pending[traffic]
+	if len(queue) == 0 || queue[0] != first {
+		b.mu.Unlock()
+		return
+	}
+	b.pending[traffic] = nil
+	b.mu.Unlock()
+
+	b.send(traffic, queue)
+}
+
+func (b *transactionBatcher) send(traffic enums.RPCTraffic, queue []*transactionRequest) {
+	ctx, cancel := context.WithTimeout(WithTraffic(context.Background(), traffic), b.timeout)
+	defer cancel()
+
+	signatures := make([]string, len(queue))
+	for i, request := range queue {
+		signatures[i] = request.signature
+	}
+
+	results, err := callWeighted(ctx, b.sc.endpoints, "getTransaction", len(queue), func(ctx context.Context, e *endpoint) ([]transactionResult, error) {
+		return b.sc.getTransactions(ctx, e, signatures)
+	})
+	for i, request := range queue {
+		if err != nil {
+			request.result <- transactionResult{err: err}
+			continue
+		}
+		request.result <- results[i]
+	}
+}
+
+// getTransactions sends one batch request to the endpoint, or one request per transaction to
+// endpoints that reject batches. An error is returned only when the batch as a whole failed;
+// errors of single transactions are part of the results.
+func (sc *SolanaClient) getTransactions(ctx context.Context, e *endpoint, signatures []string) ([]transactionResult, error) {
+	config := client.GetTransactionConfig{Commitment: sc.commitment}
+	if e.batchRejected.Load() {
+		return getTransactionsOneByOne(ctx, e, signatures, config)
+	}
+
+	requests := make([]rpc.JsonRpcRequest, len(signatures))
+	for i, signature := range signatures {
+		requests[i] = rpc.JsonRpcRequest{
+			JsonRpc: "2.0",
+			Id:      uint64(i),
+			Method:  "getTransaction",
+			Params:  []any{signature, batchTransactionConfig(config)},
+		}
+	}
+
+	responses, err := e.batch(ctx, requests)
+	if errors.Is(err, errBatchRejected) {
+		if !e.batchRejected.Swap(true) {
+			log.Warnf("RPC endpoint %s rejects batch requests; sending transactions one by one: %v", e.name, err)
+		}
+		return getTransactionsOneByOne(ctx, e, signatures, config)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]transactionResult, len(signatures))
+	for i, signature := range signatures {
+		response, ok := responses[uint64(i)]
+		if !ok {
+			results[i] = transactionResult{err: fmt.Errorf("batch response has no result for transaction %s", signature)}
+			continue
+		}
+		// Let the SDK decode the response exactly as if it had fetched it alone.
+		decoder := client.New(rpc.WithHTTPClient(&http.Client{Transport: replayTransport{body: response}}))
+		transaction, err := decoder.GetTransactionWithConfig(ctx, signature, config)
+		results[i] = transactionResult{transaction: transaction, err: err}
+	}
+	return results, nil
+}
+
+// getTransactionsOneByOne fetches the transactions concurrently with one request each. The batch
+// was already charged for all of them. It fails as a whole only when every request failed
+// without an answer from the node, so the call can fail over.
+func getTransactionsOneByOne(ctx context.Context, e *endpoint, signatures []string, config client.GetTransactionConfig) ([]transactionResult, error) {
+	results := make([]transactionResult, len(signatures))
+	statuses := make([]*responseStatus, len(signatures))
+
+	var wg sync.WaitGroup
+	for i, signature := range signatures {
+		wg.Add(1)
+		go func(i int, signature string) {
+			defer wg.Done()
+			// Each request captures its own status; they would race on the batch's
+			statuses[i] = &responseStatus{}
+			transaction, err := e.client.GetTransactionWithConfig(context.WithValue(ctx, responseStatusKey{}, statuses[i]), signature, config)
+			results[i] = transactionResult{transaction: transaction, err: err}
+		}(i, signature)
+	}
+	wg.Wait()
+
+	// Surface a rate limit on any of them, so the endpoint backs off
+	if status, ok := ctx.Value(responseStatusKey{}).(*responseStatus); ok {
+		for _, requestStatus := range statuses {
+			if requestStatus.code == http.StatusTooManyRequests {
+				*status = *requestStatus
+				break
+			}
+		}
+	}
+
+	var rpcErr *rpc.JsonRpcError
+	for _, result := range results {
+		if result.err == nil || errors.As(result.err, &rpcErr) {
+			return results, nil
+		}
+	}
+	return nil, results[0].err
+}
+
+// batchTransactionConfig mirrors the parameters the SDK sends for GetTransactionWithConfig.
+func batchTransactionConfig(config client.GetTransactionConfig) rpc.GetTransactionConfig {
+	var maxSupportedTransactionVersion uint8
+	return rpc.GetTransactionConfig{
+		Encoding:                       rpc.TransactionEncodingBase64,
+		Commitment:                     config.Commitment,
+		MaxSupportedTransactionVersion: &maxSupportedTransactionVersion,
+	}
+}
+
+// batch posts the requests as one JSON-RPC batch and returns the raw responses by request id.
+func (e *endpoint) batch(ctx context.Context, requests []rpc.JsonRpcRequest) (map[uint64]json.RawMessage, error) {
+	payload, err := json.Marshal(requests)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode batch request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create batch request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send batch request: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch response: %w", err)
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, fmt.Errorf("batch request failed with status code %d: %s", res.StatusCode, body)
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(body, &items); err != nil {
+		// Providers that reject batches answer with a single error object. A rate limit is
+		// passed on as is, so the endpoint backs off.
+		var single rpc.JsonRpcResponse[any]
+		if json.Unmarshal(body, &single) == nil && single.Error != nil {
+			if single.Error.Code == http.StatusTooManyRequests {
+				return nil, single.Error
+			}
+			return nil, fmt.Errorf("%w: %v", errBatchRejected, single.Error)
+		}
+		return nil, fmt.Errorf("failed to decode batch response: %w", err)
+	}
+
+	responses := make(map[uint64]json.RawMessage, len(items))
+	for _, item := range items {
+		var header struct {
+			Id uint64 `json:"id"`
+		}
+		if err := json.Unmarshal(item, &header); err != nil {
+			return nil, fmt.Errorf("failed to decode batch response item: %w", err)
+		}
+		responses[header.Id] = item
+	}
+	return responses, nil
+}
+
+// replayTransport answers every request with the same response body.
+type replayTransport struct {
+	body []byte
+}
+
+func (t replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(bytes.NewReader(t.body)),
+		Request:    req,
+	}, nil
+}
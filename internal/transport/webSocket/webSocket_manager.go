@@ -2,94 +2,682 @@ package webSocket
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/contracts/services"
 	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/enums"
 	log "github.com/delaram-gholampoor-sagha/SOLSniffer/internal/logger"
+	"github.com/delaram-gholampoor-sagha/SOLSniffer/internal/models/entity"
 	"github.com/gorilla/websocket"
+	"net"
 	"net/url"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	// notificationBuffer is how many notifications a subscription holds before further ones are
+	// dropped.
+	notificationBuffer = 256
+	// replayTimeout bounds resubscribing one subscription after a reconnect.
+	replayTimeout = 10 * time.Second
+	// watchInterval is how often the watchdog checks the stream and its health is recorded.
+	watchInterval = time.Second
+)
+
+// unsubscribeActions maps each subscribe action to the action that ends it.
+var unsubscribeActions = map[enums.SubscriptionAction]enums.SubscriptionAction{
+	enums.LogsSubscribe:    enums.LogsUnsubscribe,
+	enums.ProgramSubscribe: enums.ProgramUnsubscribe,
+	enums.SlotSubscribe:    enums.SlotUnsubscribe,
+	enums.AccountSubscribe: enums.AccountUnsubscribe,
+}
+
+var (
+	// ErrClosed is returned for requests made after the manager was closed or gave up
+	// reconnecting.
+	ErrClosed = errors.New("websocket connection closed")
+	// errConnectionLost is returned for requests whose connection was lost before they were
+	// answered.
+	errConnectionLost = errors.New("websocket connection lost")
+)
+
+// Backoff paces reconnect attempts. The delay doubles after every failed attempt up to MaxDelay
+// when Exponential is set. Zero Attempts keeps trying until a connection is made.
+type Backoff struct {
+	Attempts    uint
+	Delay       time.Duration
+	MaxDelay    time.Duration
+	Exponential bool
+}
+
+func (b Backoff) delay(attempt uint) time.Duration {
+	delay := b.Delay
+	if b.Exponential {
+		for i := uint(1); i < attempt && (b.MaxDelay == 0 || delay < b.MaxDelay); i++ {
+			delay *= 2
+		}
+	}
+	if b.MaxDelay > 0 && delay > b.MaxDelay {
+		delay = b.MaxDelay
+	}
+	return delay
+}
+
+// Liveness keeps a connection honest. A ping goes out every PingInterval and the connection is
+// dropped once nothing, not even a pong, was read for PongTimeout, which catches half-open
+// connections that still accept writes. The watchdog also drops a connection that delivered no
+// notification for NotificationTimeout while subscriptions are active.
+type Liveness struct {
+	PingInterval        time.Duration
+	PongTimeout         time.Duration
+	NotificationTimeout time.Duration
+}
+
+// Reconnect tells consumers the stream was down between DisconnectedAt and ReconnectedAt.
+// Notifications from that window were not delivered.
+type Reconnect struct {
+	DisconnectedAt time.Time
+	ReconnectedAt  time.Time
+}
+
+// Manager multiplexes JSON-RPC requests and subscriptions over one connection. A single reader
+// goroutine hands replies to the requests waiting for them by id and routes notifications to
+// their subscription by subscription id; writes are serialized. When the connection is lost the
+// manager reconnects, resubscribes every active subscription and tells its listeners, so
+// consumers keep reading the same subscriptions. Connections that stop answering pings or go
+// quiet are treated as lost.
 type Manager struct {
-	conn           *websocket.Conn
-	subscriptionID string
+	url      string
+	backoff  Backoff
+	liveness Liveness
+	monitor  services.Monitoring
+
+	writeMu sync.Mutex
+	nextID  atomic.Uint64
+	// lastNotification is when the last notification was read, in Unix nanoseconds
+	lastNotification atomic.Int64
+
+	mu      sync.Mutex
+	current *connection
+	// ready is closed while a connection with all subscriptions replayed is available
+	ready   chan struct{}
+	pending map[uint64]*call
+	// subscriptions holds the subscriptions of the current connection by subscription id
+	subscriptions map[string]*Subscription
+	active        map[*Subscription]struct{}
+	listeners     []func(Reconnect)
+	closing       bool
+	closed        chan struct{}
+	err           error
+}
+
+type connection struct {
+	conn *websocket.Conn
+	lost chan struct{}
+	// readyAt is when the connection's subscriptions were in place, guarded by the manager's mu
+	readyAt time.Time
+	// stale is set when the watchdog drops the connection
+	stale atomic.Bool
+}
+
+// call is a request waiting for its reply. Subscribe calls register their subscription as soon
+// as the reply is read, so no notification sent right after it is lost.
+type call struct {
+	replies      chan message
+	subscription *Subscription
 }
 
-func New(schema, host, path string) (*Manager, error) {
+// message is the envelope of everything the node sends: replies carry an id, notifications a
+// method and the subscription they belong to.
+type message struct {
+	ID     *uint64          `json:"id"`
+	Method string           `json:"method"`
+	Result json.RawMessage  `json:"result"`
+	Error  *json.RawMessage `json:"error"`
+	Params struct {
+		Subscription json.Number `json:"subscription"`
+	} `json:"params"`
+}
+
+// Subscription receives the notifications of one subscription as raw messages. It outlives
+// reconnects; only its id changes.
+type Subscription struct {
+	action        enums.SubscriptionAction
+	params        []interface{}
+	notifications chan []byte
+	done          chan struct{}
+	end           sync.Once
+
+	mu sync.Mutex
+	id string
+}
+
+func newSubscription(action enums.SubscriptionAction, params []interface{}) *Subscription {
+	return &Subscription{
+		action:        action,
+		params:        params,
+		notifications: make(chan []byte, notificationBuffer),
+		done:          make(chan struct{}),
+	}
+}
+
+// ID is the id the node assigned to the subscription on the current connection.
+func (s *Subscription) ID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.id
+}
+
+func (s *Subscription) Notifications() <-chan []byte {
+	return s.notifications
+}
+
+// Done is closed once the subscription ended, either by Unsubscribe or because the manager was
+// closed or could not resubscribe it.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *Subscription) ended() bool {
+	return isClosed(s.done)
+}
+
+func (s *Subscription) stop() {
+	s.end.Do(func() { close(s.done) })
+}
+
+func New(schema, host, path string, backoff Backoff, liveness Liveness, monitor services.Monitoring) (*Manager, error) {
 	u := url.URL{Scheme: schema, Host: host, Path: path}
-	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	manager := &Manager{
+		url:           u.String(),
+		backoff:       backoff,
+		liveness:      liveness,
+		monitor:       monitor,
+		ready:         make(chan struct{}),
+		pending:       make(map[uint64]*call),
+		subscriptions: make(map[string]*Subscription),
+		active:        make(map[*Subscription]struct{}),
+		closed:        make(chan struct{}),
+	}
+
+	c, err := manager.dial()
+	if err != nil {
+		return nil, err
+	}
+	c.readyAt = time.Now()
+	manager.current = c
+	close(manager.ready)
+	manager.lastNotification.Store(c.readyAt.UnixNano())
+	manager.start(c)
+	go manager.watch()
+	return manager, nil
+}
+
+func (w *Manager) dial() (*connection, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(w.url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect WebSocket: %w", err)
 	}
-	return &Manager{conn: conn}, nil
+	return &connection{conn: conn, lost: make(chan struct{})}, nil
 }
 
-func (w *Manager) Subscribe(ctx context.Context, action enums.SubscriptionAction) (string, error) {
-	subscribeRequest := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"id":      1,
-		"method":  string(action),
-		"params": []interface{}{
-			map[string]interface{}{
-				"mentions": []string{"any"},
-			},
-		},
+// start reads from a connection and keeps pinging it until it is lost.
+func (w *Manager) start(c *connection) {
+	c.conn.SetReadDeadline(time.Now().Add(w.liveness.PongTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(w.liveness.PongTimeout))
+	})
+	go w.read(c)
+	go w.keepAlive(c)
+}
+
+func (w *Manager) keepAlive(c *connection) {
+	ticker := time.NewTicker(w.liveness.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.lost:
+			return
+		case <-ticker.C:
+			// A failed ping is left to the read deadline, which drops the connection
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(w.liveness.PingInterval)); err != nil {
+				log.Debugf("WebSocket ping failed: %v", err)
+			}
+		}
 	}
+}
 
-	err := w.writeJSONWithContext(ctx, subscribeRequest)
-	if err != nil {
-		return "", fmt.Errorf("failed to send subscribe request: %w", err)
+// watch records the stream's health and drops a connected stream that went quiet, until the
+// manager is closed.
+func (w *Manager) watch() {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.closed:
+			w.record(entity.NewEvent(entity.StreamHealthEvent, entity.StreamHealth{}))
+			return
+		case <-ticker.C:
+		}
+
+		w.mu.Lock()
+		c := w.current
+		connected := c != nil && isClosed(w.ready)
+		subscribed := len(w.active) > 0
+		since := time.Unix(0, w.lastNotification.Load())
+		if connected && c.readyAt.After(since) {
+			since = c.readyAt
+		}
+		w.mu.Unlock()
+
+		age := time.Since(since)
+		w.record(entity.NewEvent(entity.StreamHealthEvent, entity.StreamHealth{Connected: connected, NotificationAge: age}))
+
+		timeout := w.liveness.NotificationTimeout
+		if connected && subscribed && timeout > 0 && age > timeout && !c.stale.Load() {
+			log.Warnf("No WebSocket notification for %s; reconnecting", age.Round(time.Second))
+			c.stale.Store(true)
+			c.conn.Close()
+		}
 	}
+}
 
-	var response struct {
-		Result struct {
-			Subscription string `json:"subscription"`
-		} `json:"result"`
+func (w *Manager) record(event entity.Event) {
+	if w.monitor != nil {
+		w.monitor.Record(event)
 	}
-	err = w.readJSONWithContext(ctx, &response)
-	if err != nil {
-		return "", fmt.Errorf("failed to read subscription response: %w", err)
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
 	}
+}
+
+// OnReconnect registers a listener called after every reconnect, once the subscriptions are
+// replayed.
+func (w *Manager) OnReconnect(listener func(Reconnect)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.listeners = append(w.listeners, listener)
+}
 
-	w.subscriptionID = response.Result.Subscription
-	return w.subscriptionID, nil
+// Subscribe opens a subscription with the given params. While reconnecting it waits for the
+// connection to come back.
+func (w *Manager) Subscribe(ctx context.Context, action enums.SubscriptionAction, params ...interface{}) (*Subscription, error) {
+	if params == nil {
+		params = []interface{}{}
+	}
+	subscription := newSubscription(action, params)
+
+	for {
+		reply, c, err := w.request(ctx, action, params, subscription)
+		if errors.Is(err, errConnectionLost) {
+			continue
+		}
+		if err == nil && reply.Error != nil {
+			err = fmt.Errorf("subscription rejected: %s", *reply.Error)
+		}
+		if err != nil {
+			subscription.stop()
+			return nil, fmt.Errorf("failed to subscribe with %s: %w", action, err)
+		}
+
+		// Only a subscription confirmed on the current connection is replayed by the next
+		// reconnect; one confirmed on a connection lost meanwhile is sent again.
+		w.mu.Lock()
+		if w.current == c {
+			w.active[subscription] = struct{}{}
+			w.mu.Unlock()
+			return subscription, nil
+		}
+		w.mu.Unlock()
+	}
 }
 
-func (w *Manager) Unsubscribe(ctx context.Context, action enums.SubscriptionAction) error {
+// Unsubscribe ends a subscription. It does not wait for the reply, so it also works once the
+// connection is being torn down.
+func (w *Manager) Unsubscribe(ctx context.Context, subscription *Subscription) error {
+	id := subscription.ID()
+	w.end(subscription)
+
+	w.mu.Lock()
+	c := w.current
+	w.mu.Unlock()
+	if c == nil {
+		// Reconnecting; the subscription is simply not replayed
+		return nil
+	}
+
+	action := unsubscribeActions[subscription.action]
 	request := map[string]interface{}{
 		"jsonrpc": "2.0",
-		"id":      2,
+		"id":      w.nextID.Add(1),
 		"method":  string(action),
-		"params":  []interface{}{w.subscriptionID},
+		"params":  []interface{}{json.Number(id)},
 	}
 
-	err := w.writeJSONWithContext(ctx, request)
+	err := w.writeJSONWithContext(ctx, c, request)
 	if err != nil {
 		return fmt.Errorf("failed to send unsubscribe request: %w", err)
 	}
 
-	log.Infof("Unsubscribed from %s", action)
+	log.Debugf("Unsubscribed from %s %s", subscription.action, id)
 	return nil
 }
 
-// Helper methods for context-aware WriteJSON and ReadJSON
-func (w *Manager) writeJSONWithContext(ctx context.Context, v interface{}) error {
-	done := make(chan error, 1)
-	go func() {
-		done <- w.conn.WriteJSON(v)
+// end forgets the subscription and closes its Done channel.
+func (w *Manager) end(subscription *Subscription) {
+	w.mu.Lock()
+	delete(w.active, subscription)
+	if id := subscription.ID(); w.subscriptions[id] == subscription {
+		delete(w.subscriptions, id)
+	}
+	w.mu.Unlock()
+	subscription.stop()
+}
+
+// request sends a request once the manager is connected and waits for its reply. It returns the
+// connection that answered.
+func (w *Manager) request(ctx context.Context, action enums.SubscriptionAction, params []interface{}, subscription *Subscription) (message, *connection, error) {
+	w.mu.Lock()
+	ready := w.ready
+	w.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return message{}, nil, ctx.Err()
+	case <-w.closed:
+		return message{}, nil, ErrClosed
+	case <-ready:
+	}
+
+	w.mu.Lock()
+	c := w.current
+	w.mu.Unlock()
+	if c == nil {
+		return message{}, nil, errConnectionLost
+	}
+	reply, err := w.send(ctx, c, action, params, subscription)
+	return reply, c, err
+}
+
+// send sends a request over the given connection and waits for its reply.
+func (w *Manager) send(ctx context.Context, c *connection, action enums.SubscriptionAction, params []interface{}, subscription *Subscription) (message, error) {
+	id := w.nextID.Add(1)
+	pending := &call{replies: make(chan message, 1), subscription: subscription}
+
+	w.mu.Lock()
+	w.pending[id] = pending
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		delete(w.pending, id)
+		w.mu.Unlock()
 	}()
+
+	request := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  string(action),
+		"params":  params,
+	}
+	if err := w.writeJSONWithContext(ctx, c, request); err != nil {
+		if ctx.Err() != nil {
+			return message{}, ctx.Err()
+		}
+		// A failed write leaves the connection unusable; closing it hands it to the reconnect
+		c.conn.Close()
+		return message{}, fmt.Errorf("%w: %v", errConnectionLost, err)
+	}
+
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
-	case err := <-done:
-		return err
+		return message{}, ctx.Err()
+	case <-c.lost:
+		return message{}, errConnectionLost
+	case reply := <-pending.replies:
+		return reply, nil
 	}
 }
 
-func (w *Manager) readJSONWithContext(ctx context.Context, v interface{}) error {
+// read is the only reader of a connection.
+func (w *Manager) read(c *connection) {
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			w.connectionLost(c, fmt.Errorf("error reading WebSocket message: %w", err))
+			return
+		}
+		c.conn.SetReadDeadline(time.Now().Add(w.liveness.PongTimeout))
+
+		var msg message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			log.Warnf("Failed to decode WebSocket message: %v", err)
+			continue
+		}
+
+		switch {
+		case msg.Method == "" && msg.ID != nil:
+			w.reply(msg)
+		case msg.Method != "":
+			w.route(msg.Params.Subscription.String(), data)
+		}
+	}
+}
+
+func (w *Manager) reply(msg message) {
+	w.mu.Lock()
+	pending, ok := w.pending[*msg.ID]
+	if ok && pending.subscription != nil && msg.Error == nil && !pending.subscription.ended() {
+		// The node answers with the numeric subscription id
+		var id json.Number
+		if err := json.Unmarshal(msg.Result, &id); err == nil {
+			pending.subscription.mu.Lock()
+			pending.subscription.id = id.String()
+			pending.subscription.mu.Unlock()
+			w.subscriptions[id.String()] = pending.subscription
+		}
+	}
+	w.mu.Unlock()
+
+	if ok {
+		pending.replies <- msg
+	}
+}
+
+// route delivers a notification to its subscription. The reader must not wait for a consumer:
+// every other subscription would stall and pongs would go unread until the connection counts as
+// lost, so a notification for a full subscription is dropped and counted.
+func (w *Manager) route(id string, data []byte) {
+	w.lastNotification.Store(time.Now().UnixNano())
+
+	w.mu.Lock()
+	subscription, ok := w.subscriptions[id]
+	w.mu.Unlock()
+	if !ok {
+		log.Debugf("Dropping notification for unknown subscription %s", id)
+		return
+	}
+
+	select {
+	case subscription.notifications <- data:
+	case <-subscription.done:
+	default:
+		log.Warnf("Dropping %s notification for subscription %s; its consumer is %d notifications behind", subscription.action, id, notificationBuffer)
+		w.record(entity.NewEvent(entity.StreamNotificationDroppedEvent, entity.StreamNotificationDropped{Action: subscription.action}))
+	}
+}
+
+// connectionLost fails the requests waiting on the connection and starts reconnecting, unless
+// the manager is closing.
+func (w *Manager) connectionLost(c *connection, err error) {
+	w.mu.Lock()
+	if w.current != c {
+		w.mu.Unlock()
+		return
+	}
+	w.current = nil
+	w.ready = make(chan struct{})
+	w.subscriptions = make(map[string]*Subscription)
+	close(c.lost)
+	closing := w.closing
+	w.mu.Unlock()
+
+	if closing {
+		w.fail(ErrClosed)
+		return
+	}
+
+	reason := enums.StreamError
+	var netErr net.Error
+	switch {
+	case c.stale.Load():
+		reason = enums.StreamStale
+	case errors.As(err, &netErr) && netErr.Timeout():
+		reason = enums.StreamTimeout
+	}
+	w.record(entity.NewEvent(entity.StreamDisconnectEvent, entity.StreamDisconnect{Reason: reason}))
+
+	log.Warnf("WebSocket connection lost (%s): %v", reason, err)
+	go w.reconnect(time.Now())
+}
+
+// reconnect dials until a connection is made or the attempts run out, then replays the active
+// subscriptions on it.
+func (w *Manager) reconnect(disconnectedAt time.Time) {
+	for attempt := uint(1); ; attempt++ {
+		if w.backoff.Attempts > 0 && attempt > w.backoff.Attempts {
+			log.Errorf("Giving up reconnecting WebSocket after %d attempts", w.backoff.Attempts)
+			w.fail(ErrClosed)
+			return
+		}
+
+		delay := w.backoff.delay(attempt)
+		select {
+		case <-w.closed:
+			return
+		case <-time.After(delay):
+		}
+
+		c, err := w.dial()
+		if err != nil {
+			log.Warnf("WebSocket reconnect attempt %d failed: %v", attempt, err)
+			continue
+		}
+
+		w.mu.Lock()
+		if w.closing {
+			w.mu.Unlock()
+			c.conn.Close()
+			w.fail(ErrClosed)
+			return
+		}
+		w.current = c
+		w.mu.Unlock()
+		w.start(c)
+
+		if !w.replay(c) {
+			// The new connection was lost as well and its own reconnect took over
+			return
+		}
+
+		w.mu.Lock()
+		if w.current != c {
+			w.mu.Unlock()
+			return
+		}
+		c.readyAt = time.Now()
+		close(w.ready)
+		listeners := w.listeners
+		w.mu.Unlock()
+
+		event := Reconnect{DisconnectedAt: disconnectedAt, ReconnectedAt: time.Now()}
+		log.Infof("WebSocket reconnected after %s", event.ReconnectedAt.Sub(disconnectedAt).Round(time.Millisecond))
+		for _, listener := range listeners {
+			listener(event)
+		}
+		return
+	}
+}
+
+// replay resubscribes every active subscription on the connection. Subscriptions the node
+// rejects end. It reports false if the connection was lost meanwhile or stopped answering.
+func (w *Manager) replay(c *connection) bool {
+	w.mu.Lock()
+	subscriptions := make([]*Subscription, 0, len(w.active))
+	for subscription := range w.active {
+		subscriptions = append(subscriptions, subscription)
+	}
+	w.mu.Unlock()
+
+	for _, subscription := range subscriptions {
+		ctx, cancel := context.WithTimeout(context.Background(), replayTimeout)
+		reply, err := w.send(ctx, c, subscription.action, subscription.params, subscription)
+		cancel()
+
+		switch {
+		case errors.Is(err, errConnectionLost):
+			return false
+		case err != nil:
+			// A node that does not answer is treated like a lost connection
+			log.Warnf("Failed to resubscribe with %s: %v", subscription.action, err)
+			c.conn.Close()
+			return false
+		case reply.Error != nil:
+			log.Errorf("Resubscribing with %s was rejected: %s", subscription.action, *reply.Error)
+			w.end(subscription)
+		}
+	}
+	log.Infof("Resubscribed %d WebSocket subscriptions", len(subscriptions))
+	return true
+}
+
+// fail closes the manager for good and ends every subscription.
+func (w *Manager) fail(err error) {
+	w.mu.Lock()
+	if w.err != nil {
+		w.mu.Unlock()
+		return
+	}
+	w.err = err
+	close(w.closed)
+	active := w.active
+	w.active = make(map[*Subscription]struct{})
+	w.subscriptions = make(map[string]*Subscription)
+	w.mu.Unlock()
+
+	for subscription := range active {
+		subscription.stop()
+	}
+}
+
+// Done is closed once the manager was closed or gave up reconnecting; Err then tells why.
+func (w *Manager) Done() <-chan struct{} {
+	return w.closed
+}
+
+func (w *Manager) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Helper method for context-aware WriteJSON
+func (w *Manager) writeJSONWithContext(ctx context.Context, c *connection, v interface{}) error {
 	done := make(chan error, 1)
 	go func() {
-		done <- w.conn.ReadJSON(v)
+		w.writeMu.Lock()
+		defer w.writeMu.Unlock()
+		done <- c.conn.WriteJSON(v)
 	}()
 	select {
 	case <-ctx.Done():
@@ -99,32 +687,29 @@ func (w *Manager) readJSONWithContext(ctx context.Context, v interface{}) error
 	}
 }
 
+// IsConnected reports whether a live connection with its subscriptions in place is available.
+// Connections that stop answering pings are dropped, so a connected manager has heard from the
+// node within the pong timeout.
 func (w *Manager) IsConnected() bool {
-	return w.conn != nil && w.pingConnection()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current != nil && isClosed(w.ready)
 }
 
-func (w *Manager) pingConnection() bool {
-	if err := w.conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(1*time.Second)); err != nil {
-		log.Debugf("WebSocket connection ping failed")
-		return false
-	}
-	return true
-}
+// Close closes the connection for good; no reconnect follows.
+func (w *Manager) Close() error {
+	w.mu.Lock()
+	w.closing = true
+	c := w.current
+	w.mu.Unlock()
 
-func (w *Manager) ReadMessage() ([]byte, error) {
-	_, message, err := w.conn.ReadMessage()
-	if err != nil {
-		return nil, fmt.Errorf("error reading WebSocket message: %w", err)
+	if c == nil {
+		w.fail(ErrClosed)
+		return nil
 	}
-	return message, nil
-}
-
-func (w *Manager) Close() error {
-	if w.conn != nil {
-		if err := w.conn.Close(); err != nil {
-			return fmt.Errorf("failed to close WebSocket connection: %w", err)
-		}
-		log.Infof("WebSocket connection closed")
+	if err := c.conn.Close(); err != nil {
+		return fmt.Errorf("failed to close WebSocket connection: %w", err)
 	}
+	log.Infof("WebSocket connection closed")
 	return nil
 }